@@ -0,0 +1,68 @@
+package aesgcm256
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+)
+
+const (
+	V2Name = "aesgcm256-v2"
+)
+
+var (
+	errCiphertextTooShort = errors.New("aesgcm256-v2: ciphertext too short")
+)
+
+// aesGcm256V2 is AES-GCM-256 with support for associated data. The nonce is stored in front of the
+// sealed data.
+type aesGcm256V2 struct{}
+
+func NewV2() *aesGcm256V2 {
+	return &aesGcm256V2{}
+}
+
+func (c *aesGcm256V2) Encrypt(key []byte, data []byte) ([]byte, error) {
+	return c.Seal(key, data, nil)
+}
+
+func (c *aesGcm256V2) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+	return c.Open(key, ciphertext, nil)
+}
+
+func (c *aesGcm256V2) Seal(key []byte, data []byte, associatedData []byte) ([]byte, error) {
+	block, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, block.NonceSize(), block.NonceSize()+len(data)+block.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return block.Seal(nonce, nonce, data, associatedData), nil
+}
+
+func (c *aesGcm256V2) Open(key []byte, ciphertext []byte, associatedData []byte) ([]byte, error) {
+	block, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < block.NonceSize()+block.Overhead() {
+		return nil, errCiphertextTooShort
+	}
+	nonce := ciphertext[:block.NonceSize()]
+	return block.Open(nil, nonce, ciphertext[block.NonceSize():], associatedData)
+}
+
+func (c *aesGcm256V2) NeedsKey() bool {
+	return true
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	aes, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(aes)
+}
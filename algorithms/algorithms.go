@@ -16,6 +16,14 @@ type Algorithm interface {
 	NeedsKey() bool
 }
 
+// AEAD is implemented by Algorithms that authenticate associated data along with the ciphertext.
+// Ciphertexts produced by Seal can only be opened by passing the same associated data to Open.
+type AEAD interface {
+	Algorithm
+	Seal(key []byte, data []byte, associatedData []byte) ([]byte, error)
+	Open(key []byte, ciphertext []byte, associatedData []byte) ([]byte, error)
+}
+
 // Register adds a value to the store of all algorithms
 func Register(name string, a Algorithm) error {
 	_, ok := registry[name]
@@ -9,6 +9,7 @@ import (
 	"github.com/dcoker/biscuit/algorithms"
 	"github.com/dcoker/biscuit/algorithms/aesgcm256"
 	"github.com/dcoker/biscuit/algorithms/secretbox"
+	"github.com/dcoker/biscuit/algorithms/xchacha20poly1305"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,6 +27,10 @@ func TestAlgorithms(t *testing.T) {
 	assert.NoError(t, err)
 	err = algorithms.Register(aesgcm256.Name, aesgcm256.New())
 	assert.NoError(t, err)
+	err = algorithms.Register(aesgcm256.V2Name, aesgcm256.NewV2())
+	assert.NoError(t, err)
+	err = algorithms.Register(xchacha20poly1305.Name, xchacha20poly1305.New())
+	assert.NoError(t, err)
 
 	algos := algorithms.GetRegisteredAlgorithmsNames()
 
@@ -58,3 +63,28 @@ func TestAlgorithms(t *testing.T) {
 		}
 	}
 }
+
+func TestAEADAlgorithms(t *testing.T) {
+	var key [32]byte
+	_, err := rand.Read(key[:])
+	assert.NoError(t, err)
+
+	for _, algo := range []algorithms.AEAD{aesgcm256.NewV2(), xchacha20poly1305.New()} {
+		ciphertext, err := algo.Seal(key[:], []byte("launch codes"), []byte("name"))
+		assert.NoError(t, err)
+
+		plaintext, err := algo.Open(key[:], ciphertext, []byte("name"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("launch codes"), plaintext)
+
+		// different or missing associated data
+		_, err = algo.Open(key[:], ciphertext, []byte("other name"))
+		assert.Error(t, err)
+		_, err = algo.Open(key[:], ciphertext, nil)
+		assert.Error(t, err)
+
+		// truncated ciphertexts are rejected rather than causing a panic
+		_, err = algo.Open(key[:], ciphertext[:10], []byte("name"))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,60 @@
+package xchacha20poly1305
+
+import (
+	"crypto/rand"
+	"errors"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	Name = "xchacha20poly1305"
+)
+
+var (
+	errCiphertextTooShort = errors.New("xchacha20poly1305: ciphertext too short")
+)
+
+// xChaCha20Poly1305 is XChaCha20-Poly1305 with support for associated data. The 24-byte nonce is
+// stored in front of the sealed data.
+type xChaCha20Poly1305 struct{}
+
+func New() *xChaCha20Poly1305 {
+	return &xChaCha20Poly1305{}
+}
+
+func (x *xChaCha20Poly1305) Encrypt(key []byte, data []byte) ([]byte, error) {
+	return x.Seal(key, data, nil)
+}
+
+func (x *xChaCha20Poly1305) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+	return x.Open(key, ciphertext, nil)
+}
+
+func (x *xChaCha20Poly1305) Seal(key []byte, data []byte, associatedData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, associatedData), nil
+}
+
+func (x *xChaCha20Poly1305) Open(key []byte, ciphertext []byte, associatedData []byte) ([]byte, error) {
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return nil, errCiphertextTooShort
+	}
+	nonce := ciphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, ciphertext[aead.NonceSize():], associatedData)
+}
+
+func (x *xChaCha20Poly1305) NeedsKey() bool {
+	return true
+}
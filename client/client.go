@@ -0,0 +1,197 @@
+// Package client reads and writes biscuit secrets from Go programs, without running the biscuit
+// command.
+//
+//	secrets, err := client.Open("secrets.yml", client.WithRegionPriority("us-west-2"))
+//	if err != nil {
+//		return err
+//	}
+//	password, err := secrets.Get(ctx, "db_password")
+//
+// Locations are the same as those accepted by the --filename flag: a path, or a URL such as
+// s3://bucket/secrets.yml. Values are decrypted with the algorithms built in to biscuit and the key
+// managers registered with the keymanager package; WithAlgorithm and WithKeyManager add others or
+// replace them.
+package client
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"sync"
+	"time"
+
+	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+	"github.com/dcoker/biscuit/store/dynamodbstore"
+	"github.com/dcoker/biscuit/store/s3store"
+	"github.com/dcoker/biscuit/store/ssmstore"
+)
+
+// Client reads and writes the secrets in a Store.
+type Client struct {
+	codec
+	store          store.Store
+	regionPriority []string
+	keys           []store.Key
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithRegionPriority makes Get and GetAll try values encrypted under AWS KMS keys in these regions
+// first, in this order.
+func WithRegionPriority(regions ...string) Option {
+	return func(c *Client) {
+		c.regionPriority = regions
+	}
+}
+
+// WithAlgorithm makes an algorithm available under name, in place of any built-in or registered
+// algorithm with that name.
+func WithAlgorithm(name string, algorithm algorithms.Algorithm) Option {
+	return func(c *Client) {
+		c.algorithms[name] = algorithm
+	}
+}
+
+// WithKeyManager makes a key manager available under its label, in place of any registered key
+// manager with that label.
+func WithKeyManager(keyManager keymanager.KeyManager) Option {
+	return func(c *Client) {
+		c.keyManagers[keyManager.Label()] = keyManager
+	}
+}
+
+// WithKeys sets the keys that Put encrypts new values under. By default, Put uses the keys in the
+// store's template, as the put command does.
+func WithKeys(keys ...store.Key) Option {
+	return func(c *Client) {
+		c.keys = keys
+	}
+}
+
+var registerStores sync.Once
+
+// Open returns a Client for the secrets at location, which is a path or a URL. The s3, ssm and
+// dynamodb backends are registered with the store package if they have not been already.
+func Open(location string, opts ...Option) (*Client, error) {
+	registerStores.Do(func() {
+		// Register fails only if the scheme is already registered, which is fine.
+		_ = store.Register(s3store.Scheme, s3store.Open)
+		_ = store.Register(ssmstore.Scheme, ssmstore.Open)
+		_ = store.Register(dynamodbstore.Scheme, dynamodbstore.Open)
+	})
+	database, err := store.Open(context.Background(), location)
+	if err != nil {
+		return nil, err
+	}
+	return New(database, opts...), nil
+}
+
+// New returns a Client for the secrets in database.
+func New(database store.Store, opts ...Option) *Client {
+	c := &Client{
+		codec: codec{
+			algorithms:  make(map[string]algorithms.Algorithm),
+			keyManagers: make(map[string]keymanager.KeyManager),
+		},
+		store: database,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get returns the plaintext of the secret called name. The values of the secret are tried in order
+// of region priority until one decrypts. If the secret does not exist, the error is a
+// *NotFoundError; if no value decrypts, it is a *DecryptError.
+func (c *Client) Get(ctx context.Context, name string) ([]byte, error) {
+	values, err := c.store.Get(name)
+	if errors.Is(err, store.ErrNameNotFound) {
+		return nil, &NotFoundError{name}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.decryptFirst(ctx, name, values)
+}
+
+// GetAll returns the plaintext of every secret, keyed by name. It fails if any secret cannot be
+// decrypted.
+func (c *Client) GetAll(ctx context.Context) (map[string][]byte, error) {
+	entries, err := c.store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	plaintexts := make(map[string][]byte)
+	for name, values := range entries {
+		if name == store.KeyTemplateName {
+			continue
+		}
+		plaintext, err := c.decryptFirst(ctx, name, values)
+		if err != nil {
+			return nil, err
+		}
+		plaintexts[name] = plaintext
+	}
+	return plaintexts, nil
+}
+
+// Put encrypts value under each of the keys set by WithKeys, or in the store's template, and stores
+// it as the secret called name. It returns ErrNoKeys if there are no keys to use.
+func (c *Client) Put(ctx context.Context, name string, value []byte) error {
+	keys := c.keys
+	if len(keys) == 0 {
+		var err error
+		keys, err = c.store.GetKeyIds()
+		if err != nil && !errors.Is(err, store.ErrNoTemplate) && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if len(keys) == 0 {
+			return ErrNoKeys
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	metadata := store.Metadata{Updated: now, Changed: now}
+	var values store.ValueList
+	for _, key := range keys {
+		encrypted, err := c.encrypt(ctx, key, name, value)
+		if err != nil {
+			return err
+		}
+		encrypted.Metadata = metadata
+		values = append(values, encrypted)
+	}
+
+	return c.store.Update(func(entries store.EntryMap) error {
+		if len(entries) == 0 {
+			var template store.ValueList
+			for _, key := range keys {
+				template = append(template, store.Value{Key: key})
+			}
+			entries[store.KeyTemplateName] = template
+		}
+		entries[name] = store.InheritMetadata(values, entries[name], store.Metadata{})
+		return nil
+	})
+}
+
+// decryptFirst returns the plaintext of the first of values that decrypts, in order of region
+// priority.
+func (c *Client) decryptFirst(ctx context.Context, name string, values store.ValueList) ([]byte, error) {
+	values = append(store.ValueList(nil), values...)
+	store.SortByKmsRegion(c.regionPriority)(values)
+	ctx = keymanager.WithRegionPriority(ctx, c.regionPriority)
+	failure := &DecryptError{Name: name}
+	for _, value := range values {
+		plaintext, err := c.decrypt(ctx, value, name)
+		if err == nil {
+			return plaintext, nil
+		}
+		failure.Values = append(failure.Values, &ValueError{Key: value.Key, Err: err})
+	}
+	return nil, failure
+}
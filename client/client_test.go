@@ -0,0 +1,198 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dcoker/biscuit/client"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKeys stands in for AWS KMS. It is a key manager whose key ciphertext is the key ID. It records the keys it is asked to
+// decrypt with, and fails for the key IDs in broken.
+type fakeKeys struct {
+	decrypted []string
+	broken    map[string]bool
+}
+
+func (f *fakeKeys) GenerateEnvelopeKey(_ context.Context, keyID, _ string) (keymanager.EnvelopeKey, error) {
+	return keymanager.EnvelopeKey{
+		ResolvedID: keyID,
+		Plaintext:  bytes.Repeat([]byte{'k'}, 32),
+		Ciphertext: []byte(keyID),
+	}, nil
+}
+
+func (f *fakeKeys) Decrypt(_ context.Context, keyID string, keyCiphertext []byte, _ string) ([]byte, error) {
+	f.decrypted = append(f.decrypted, keyID)
+	if f.broken[keyID] || string(keyCiphertext) != keyID {
+		return nil, errors.New("access denied")
+	}
+	return bytes.Repeat([]byte{'k'}, 32), nil
+}
+
+// Label returns the label of AWS KMS, so that values are sorted by region.
+func (f *fakeKeys) Label() string {
+	return keymanager.KmsLabel
+}
+
+// rot13 is an algorithm that isn't built in.
+type rot13 struct{}
+
+func (rot13) Encrypt(_ []byte, data []byte) ([]byte, error) {
+	return bytes.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return 'a' + (r-'a'+13)%26
+		}
+		return r
+	}, data), nil
+}
+
+func (r rot13) Decrypt(key []byte, ciphertext []byte) ([]byte, error) {
+	return r.Encrypt(key, ciphertext)
+}
+
+func (rot13) NeedsKey() bool {
+	return false
+}
+
+const (
+	westKey = "arn:aws:kms:us-west-2:123456789012:key/west"
+	eastKey = "arn:aws:kms:us-east-1:123456789012:key/east"
+)
+
+func keys(algorithm string, keyIDs ...string) client.Option {
+	var keys []store.Key
+	for _, keyID := range keyIDs {
+		keys = append(keys, store.Key{KeyID: keyID, KeyManager: keymanager.KmsLabel, Algorithm: algorithm})
+	}
+	return client.WithKeys(keys...)
+}
+
+func TestClient_putGet(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "secrets.yml")
+	fake := &fakeKeys{}
+	writer, err := client.Open(path, client.WithKeyManager(fake), keys("aesgcm256-v2", westKey, eastKey))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Put(ctx, "password", []byte("god")))
+
+	// Later writes use the keys in the template that the first Put created.
+	reader, err := client.Open(path, client.WithKeyManager(fake))
+	assert.NoError(t, err)
+	assert.NoError(t, reader.Put(ctx, "username", []byte("oreilly")))
+	values, err := store.NewFileStore(path).Get("username")
+	assert.NoError(t, err)
+	assert.Len(t, values, 2)
+
+	plaintext, err := reader.Get(ctx, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("god"), plaintext)
+
+	all, err := reader.GetAll(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"password": []byte("god"), "username": []byte("oreilly")}, all)
+}
+
+func TestClient_Put_noKeys(t *testing.T) {
+	c, err := client.Open(filepath.Join(t.TempDir(), "secrets.yml"))
+	assert.NoError(t, err)
+	assert.Equal(t, client.ErrNoKeys, c.Put(context.Background(), "password", []byte("god")))
+}
+
+func TestClient_Get_notFound(t *testing.T) {
+	ctx := context.Background()
+	c, err := client.Open(filepath.Join(t.TempDir(), "secrets.yml"), client.WithKeys(store.Key{Algorithm: "none"}))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Put(ctx, "password", []byte("god")))
+
+	_, err = c.Get(ctx, "username")
+	var notFound *client.NotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "username", notFound.Name)
+	assert.True(t, errors.Is(err, store.ErrNameNotFound))
+}
+
+func TestClient_Get_regionPriority(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "secrets.yml")
+	fake := &fakeKeys{}
+	writer, err := client.Open(path, client.WithKeyManager(fake), keys("secretbox", westKey, eastKey))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Put(ctx, "password", []byte("god")))
+
+	reader, err := client.Open(path, client.WithKeyManager(fake), client.WithRegionPriority("us-east-1"))
+	assert.NoError(t, err)
+	_, err = reader.Get(ctx, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{eastKey}, fake.decrypted)
+
+	// If the preferred region fails, the other is tried.
+	fake.decrypted = nil
+	fake.broken = map[string]bool{eastKey: true}
+	plaintext, err := reader.Get(ctx, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("god"), plaintext)
+	assert.Equal(t, []string{eastKey, westKey}, fake.decrypted)
+}
+
+func TestClient_Get_decryptError(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeKeys{broken: map[string]bool{westKey: true, eastKey: true}}
+	c, err := client.Open(filepath.Join(t.TempDir(), "secrets.yml"), client.WithKeyManager(fake),
+		keys("secretbox", westKey, eastKey))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Put(ctx, "password", []byte("god")))
+
+	_, err = c.Get(ctx, "password")
+	var decryptErr *client.DecryptError
+	assert.True(t, errors.As(err, &decryptErr))
+	assert.Equal(t, "password", decryptErr.Name)
+	assert.Len(t, decryptErr.Values, 2)
+	assert.Equal(t, westKey, decryptErr.Values[0].Key.KeyID)
+	assert.EqualError(t, decryptErr.Values[0].Err, "access denied")
+
+	_, err = c.GetAll(ctx)
+	assert.True(t, errors.As(err, &decryptErr))
+}
+
+func TestClient_unsupported(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "secrets.yml")
+	c, err := client.Open(path, client.WithKeys(store.Key{Algorithm: "rot13"}))
+	assert.NoError(t, err)
+	var algorithmErr *client.UnsupportedAlgorithmError
+	assert.True(t, errors.As(c.Put(ctx, "password", []byte("god")), &algorithmErr))
+	assert.Equal(t, "rot13", algorithmErr.Algorithm)
+
+	c, err = client.Open(path, client.WithKeys(store.Key{KeyID: westKey, KeyManager: "fake", Algorithm: "secretbox"}))
+	assert.NoError(t, err)
+	var keyManagerErr *client.UnsupportedKeyManagerError
+	assert.True(t, errors.As(c.Put(ctx, "password", []byte("god")), &keyManagerErr))
+	assert.Equal(t, "fake", keyManagerErr.KeyManager)
+
+	c, err = client.Open(path, client.WithKeys(store.Key{Algorithm: "rot13"}), client.WithAlgorithm("rot13", rot13{}))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Put(ctx, "password", []byte("god")))
+	values, err := store.NewFileStore(path).Get("password")
+	assert.NoError(t, err)
+	assert.Equal(t, "dGJx", values[0].Ciphertext)
+	plaintext, err := c.Get(ctx, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("god"), plaintext)
+}
+
+func TestDecryptValue(t *testing.T) {
+	ctx := context.Background()
+	value, err := client.EncryptValue(ctx, store.Key{Algorithm: "none"}, "password", []byte("god"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Z29k", value.Ciphertext)
+	plaintext, err := client.DecryptValue(ctx, value, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("god"), plaintext)
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/algorithms/aesgcm256"
+	"github.com/dcoker/biscuit/algorithms/plain"
+	"github.com/dcoker/biscuit/algorithms/secretbox"
+	"github.com/dcoker/biscuit/algorithms/xchacha20poly1305"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+)
+
+// builtinAlgorithms are available whether or not they have been registered with the algorithms
+// package.
+var builtinAlgorithms = map[string]algorithms.Algorithm{
+	secretbox.Name:         secretbox.New(),
+	plain.Name:             plain.New(),
+	aesgcm256.Name:         aesgcm256.New(),
+	aesgcm256.V2Name:       aesgcm256.NewV2(),
+	xchacha20poly1305.Name: xchacha20poly1305.New(),
+}
+
+// codec encrypts and decrypts Values, looking up algorithms and key managers first in its own maps,
+// then in the algorithms and keymanager registries.
+type codec struct {
+	algorithms  map[string]algorithms.Algorithm
+	keyManagers map[string]keymanager.KeyManager
+}
+
+func (c codec) algorithm(name string) (algorithms.Algorithm, error) {
+	if algo, ok := c.algorithms[name]; ok {
+		return algo, nil
+	}
+	if algo, err := algorithms.Get(name); err == nil {
+		return algo, nil
+	}
+	if algo, ok := builtinAlgorithms[name]; ok {
+		return algo, nil
+	}
+	return nil, &UnsupportedAlgorithmError{name}
+}
+
+func (c codec) keyManager(label string) (keymanager.KeyManager, error) {
+	if keyManager, ok := c.keyManagers[label]; ok {
+		return keyManager, nil
+	}
+	if keyManager, err := keymanager.New(label); err == nil {
+		return keyManager, nil
+	}
+	return nil, &UnsupportedKeyManagerError{label}
+}
+
+// encrypt encrypts plaintext, the value of the secret called name, under key.
+func (c codec) encrypt(ctx context.Context, key store.Key, name string, plaintext []byte) (store.Value, error) {
+	var value store.Value
+	algo, err := c.algorithm(key.Algorithm)
+	if err != nil {
+		return value, err
+	}
+	value.Algorithm = key.Algorithm
+
+	var envelopeKey keymanager.EnvelopeKey
+	if algo.NeedsKey() {
+		keyManager, err := c.keyManager(key.KeyManager)
+		if err != nil {
+			return value, err
+		}
+		value.KeyManager = keyManager.Label()
+		envelopeKey, err = keyManager.GenerateEnvelopeKey(ctx, key.KeyID, name)
+		if err != nil {
+			return value, err
+		}
+		value.KeyID = envelopeKey.ResolvedID
+		value.KeyCiphertext = base64.StdEncoding.EncodeToString(envelopeKey.Ciphertext)
+	}
+
+	var ciphertext []byte
+	if aead, ok := algo.(algorithms.AEAD); ok {
+		ciphertext, err = aead.Seal(envelopeKey.Plaintext, plaintext, value.AssociatedData(name))
+	} else {
+		ciphertext, err = algo.Encrypt(envelopeKey.Plaintext, plaintext)
+	}
+	if err != nil {
+		return value, err
+	}
+	value.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
+	return value, nil
+}
+
+// decrypt returns the plaintext of value, one of the values of the secret called name.
+func (c codec) decrypt(ctx context.Context, value store.Value, name string) ([]byte, error) {
+	algo, err := c.algorithm(value.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var keyPlaintext []byte
+	if algo.NeedsKey() {
+		keyManager, err := c.keyManager(value.KeyManager)
+		if err != nil {
+			return nil, err
+		}
+		keyCiphertext, err := value.GetKeyCiphertext()
+		if err != nil {
+			return nil, err
+		}
+		keyPlaintext, err = keyManager.Decrypt(ctx, value.KeyID, keyCiphertext, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	decoded, err := value.GetCiphertext()
+	if err != nil {
+		return nil, err
+	}
+	if aead, ok := algo.(algorithms.AEAD); ok {
+		return aead.Open(keyPlaintext, decoded, value.AssociatedData(name))
+	}
+	return algo.Decrypt(keyPlaintext, decoded)
+}
+
+// EncryptValue encrypts plaintext, the value of the secret called name, under key. It uses the
+// algorithms and key managers registered with the algorithms and keymanager packages, and the
+// built-in algorithms.
+func EncryptValue(ctx context.Context, key store.Key, name string, plaintext []byte) (store.Value, error) {
+	return codec{}.encrypt(ctx, key, name, plaintext)
+}
+
+// DecryptValue returns the plaintext of value, one of the values of the secret called name. It uses
+// the algorithms and key managers registered with the algorithms and keymanager packages, and the
+// built-in algorithms.
+func DecryptValue(ctx context.Context, value store.Value, name string) ([]byte, error) {
+	return codec{}.decrypt(ctx, value, name)
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dcoker/biscuit/store"
+)
+
+// ErrNoKeys is returned by Put when no keys were given with WithKeys and the store has no template
+// to take them from.
+var ErrNoKeys = errors.New("no keys to encrypt under: pass WithKeys, or create the store with kms init")
+
+// NotFoundError is returned when a secret does not exist. It matches store.ErrNameNotFound with
+// errors.Is.
+type NotFoundError struct {
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Name, store.ErrNameNotFound)
+}
+
+// Unwrap returns store.ErrNameNotFound.
+func (e *NotFoundError) Unwrap() error {
+	return store.ErrNameNotFound
+}
+
+// UnsupportedAlgorithmError is returned when a value uses an algorithm that is neither built in nor
+// given with WithAlgorithm.
+type UnsupportedAlgorithmError struct {
+	Algorithm string
+}
+
+func (e *UnsupportedAlgorithmError) Error() string {
+	return fmt.Sprintf("algorithm %v not registered", e.Algorithm)
+}
+
+// UnsupportedKeyManagerError is returned when a value uses a key manager that is neither built in
+// nor given with WithKeyManager.
+type UnsupportedKeyManagerError struct {
+	KeyManager string
+}
+
+func (e *UnsupportedKeyManagerError) Error() string {
+	return fmt.Sprintf("unsupported key manager '%s'", e.KeyManager)
+}
+
+// ValueError describes the failure to decrypt one Value of a secret.
+type ValueError struct {
+	Key store.Key
+	Err error
+}
+
+func (e *ValueError) Error() string {
+	if e.Key.KeyManager == "" {
+		return fmt.Sprintf("%s: %s", e.Key.Algorithm, e.Err)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Key.KeyManager, e.Key.KeyID, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// DecryptError is returned by Get when none of the values of a secret could be decrypted.
+type DecryptError struct {
+	Name string
+	// Values lists the failure for each value, in the order they were tried.
+	Values []*ValueError
+}
+
+func (e *DecryptError) Error() string {
+	if len(e.Values) == 0 {
+		return fmt.Sprintf("%s: the secret has no values", e.Name)
+	}
+	var reasons []string
+	for _, value := range e.Values {
+		reasons = append(reasons, value.Error())
+	}
+	return fmt.Sprintf("%s: could not decrypt any value: %s", e.Name, strings.Join(reasons, "; "))
+}
+
+// Unwrap returns the failure of the last value tried, so that errors.As can find, for example, the
+// error returned by AWS KMS.
+func (e *DecryptError) Unwrap() error {
+	if len(e.Values) == 0 {
+		return nil
+	}
+	return e.Values[len(e.Values)-1]
+}
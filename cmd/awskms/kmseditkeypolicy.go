@@ -15,7 +15,6 @@ import (
 )
 
 var (
-	errNoEditorFound        = errors.New("Set your editor preference with VISUAL or EDITOR environment variables.")
 	errNewPolicyIsZeroBytes = errors.New("No change: the new policy is empty.")
 	errFileUnchanged        = errors.New("No change: the new policy is the same as the existing policy.")
 )
@@ -79,7 +78,7 @@ func launchEditor(contents string) (string, error) {
 		return "", err
 	}
 
-	editor, err := findEditor()
+	editor, err := shared.FindEditor()
 	if err != nil {
 		return "", err
 	}
@@ -106,16 +105,6 @@ func launchEditor(contents string) (string, error) {
 	return newContents, nil
 }
 
-func findEditor() (string, error) {
-	for _, name := range []string{"VISUAL", "EDITOR"} {
-		candidate := os.Getenv(name)
-		if len(candidate) > 0 {
-			return candidate, nil
-		}
-	}
-	return "", errNoEditorFound
-}
-
 func prettifyJSON(content string) (string, error) {
 	var v interface{}
 	if err := json.Unmarshal([]byte(content), &v); err != nil {
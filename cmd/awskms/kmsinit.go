@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io/fs"
 	"os"
 	"sort"
 	"strings"
@@ -16,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go"
 	"github.com/dcoker/biscuit/cmd/internal/shared"
@@ -40,10 +40,12 @@ type kmsInit struct {
 	createMissingKeys *bool
 	createSimpleRoles *bool
 	disableIam        *bool
+	multiRegionKey    *bool
 	administratorArns,
 	userArns,
 	filename,
 	algorithm,
+	keySpec,
 	cloudformationTemplateURL *string
 	keyCloudformationTemplate string
 }
@@ -81,60 +83,100 @@ func NewKmsInit(c *kingpin.CmdClause, keyCloudformationTemplate string) shared.C
 		"Full URL to the CloudFormation template to use. This overrides the built-in template.").
 		PlaceHolder("URL").
 		String()
+	params.multiRegionKey = c.Flag("multi-region-key",
+		"Create a single multi-Region key, with its primary in the first of --regions and replicas in "+
+			"the others, instead of an independent key in each region. The template then holds the "+
+			"primary key alone, so each secret is stored once and can be decrypted by the replica in "+
+			"any of the regions.").Bool()
+	params.keySpec = c.Flag("key-spec",
+		"The type of key to create. With an RSA key spec, the keys are asymmetric: secrets are encrypted "+
+			"locally with the public key, using the "+keymanager.KmsRsaLabel+" key manager, so that "+
+			"hosts allowed only kms:DescribeKey and kms:GetPublicKey can put secrets. Options: "+
+			strings.Join(keySpecs, ", ")).
+		Default(string(kmstypes.KeySpecSymmetricDefault)).
+		Enum(keySpecs...)
 	params.filename = shared.FilenameFlag(c)
 	params.algorithm = shared.AlgorithmFlag(c)
 	return params
 }
 
+// keySpecs are the key specs that kms init can create.
+var keySpecs = []string{
+	string(kmstypes.KeySpecSymmetricDefault),
+	string(kmstypes.KeySpecRsa2048),
+	string(kmstypes.KeySpecRsa3072),
+	string(kmstypes.KeySpecRsa4096),
+}
+
+// keyManager returns the label of the key manager for keys of the requested key spec.
+func (w *kmsInit) keyManager() string {
+	if *w.keySpec == string(kmstypes.KeySpecSymmetricDefault) {
+		return keymanager.KmsLabel
+	}
+	return keymanager.KmsRsaLabel
+}
+
 // Run runs the command.
 func (w *kmsInit) Run(ctx context.Context) error {
-	regionKeys, err := w.discoverOrCreateKeys(ctx)
+	regionKeys, primaryKeyArn, err := w.discoverOrCreateKeys(ctx)
 	if err != nil {
 		return err
 	}
+	templateKeys := stringStringMapValues(regionKeys)
+	if primaryKeyArn != "" {
+		templateKeys = []string{primaryKeyArn}
+	}
 
-	database := store.NewFileStore(*w.filename)
-
-	// If the file exists, we'll make changes to its template rather than replace it.
-	keyConfigs, err := database.Get(store.KeyTemplateName)
-	if err != nil && !(err == store.ErrNameNotFound || errors.Is(err, fs.ErrNotExist)) {
+	database, err := store.Open(ctx, *w.filename)
+	if err != nil {
 		return err
 	}
 
-	// Convert keyConfigs into a map of KeyID -> Value so that we can replace any existing
-	// entries for these keys. This allows the algorithm parameter to change w/o creating
-	// duplicate entries, and leaves other entries alone.
-	keyIDToValue := make(map[string]store.Value)
-	for _, value := range keyConfigs {
-		keyIDToValue[keymanager.KmsLabel+value.KeyID] = value
-	}
+	// If the file exists, we'll make changes to its template rather than replace it.
+	err = database.Update(func(entries store.EntryMap) error {
+		// Convert the template into a map of KeyID -> Value so that we can replace any existing
+		// entries for these keys. This allows the algorithm parameter to change w/o creating
+		// duplicate entries, and leaves other entries alone.
+		keyIDToValue := make(map[string]store.Value)
+		for _, value := range entries[store.KeyTemplateName] {
+			keyIDToValue[value.KeyManager+value.KeyID] = value
+		}
 
-	// Iterate over the discovered/created keys and set values for them in keyIDToValue.
-	for _, keyArn := range regionKeys {
-		keyIDToValue[keymanager.KmsLabel+keyArn] = store.Value{
-			Key: store.Key{
-				KeyID:      keyArn,
-				KeyManager: keymanager.KmsLabel,
-				Algorithm:  *w.algorithm,
-			},
+		// Iterate over the discovered/created keys and set values for them in keyIDToValue.
+		for _, keyArn := range templateKeys {
+			keyIDToValue[w.keyManager()+keyArn] = store.Value{
+				Key: store.Key{
+					KeyID:      keyArn,
+					KeyManager: w.keyManager(),
+					Algorithm:  *w.algorithm,
+				},
+			}
 		}
-	}
 
-	// Turn keyIDToValue back into an array by converting the map values into a list.
-	var updatedTemplate []store.Value
-	for _, v := range keyIDToValue {
-		updatedTemplate = append(updatedTemplate, v)
+		// Turn keyIDToValue back into an array by converting the map values into a list.
+		var updatedTemplate []store.Value
+		for _, v := range keyIDToValue {
+			updatedTemplate = append(updatedTemplate, v)
+		}
+		entries[store.KeyTemplateName] = updatedTemplate
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	fmt.Printf("The template used by %s has been updated to include %s: %s.\n",
 		*w.filename,
-		stringsFunc.Pluralize("key", len(regionKeys)),
-		stringStringMapValues(regionKeys))
-
-	return database.Put(store.KeyTemplateName, updatedTemplate)
+		stringsFunc.Pluralize("key", len(templateKeys)),
+		templateKeys)
+	if primaryKeyArn != "" {
+		fmt.Printf("Values encrypted under it can be decrypted in %s.\n",
+			stringsFunc.FriendlyJoin(sortedKeys(regionKeys)))
+	}
+	return nil
 }
 
-func collectRegionInfo(ctx context.Context, stackName, keyAlias string, regions []string) (map[string]string, []string, error) {
+func collectRegionInfo(ctx context.Context, stackName, keyAlias, keySpec string, regions []string) (map[string]string, []string, error) {
 	regionErrors := make(map[string][]error)
 	regionKeys := make(map[string]string)
 	var regionsMissing []string
@@ -149,7 +191,7 @@ func collectRegionInfo(ctx context.Context, stackName, keyAlias string, regions
 			stackExists = exists
 		}
 
-		if regionKey, err := checkKmsKeyExists(ctx, keyAlias, region); err != nil {
+		if regionKey, err := checkKmsKeyExists(ctx, keyAlias, keySpec, region); err != nil {
 			regionErrors[region] = append(regionErrors[region], err)
 		} else if len(regionKey) > 0 {
 			keyExists = true
@@ -200,7 +242,7 @@ func checkCloudFormationStackExists(ctx context.Context, stackName, region strin
 	return true, nil
 }
 
-func checkKmsKeyExists(ctx context.Context, keyAlias, region string) (string, error) {
+func checkKmsKeyExists(ctx context.Context, keyAlias, keySpec, region string) (string, error) {
 	cfg := myAWS.MustNewConfig(ctx, config.WithRegion(region))
 	kmsClient := kms.NewFromConfig(cfg)
 	p := kms.NewListAliasesPaginator(kmsClient, &kms.ListAliasesInput{})
@@ -227,13 +269,21 @@ func checkKmsKeyExists(ctx context.Context, keyAlias, region string) (string, er
 						"aws --region "+
 						"%s kms delete-alias --alias-name %s", region, region, keyAlias)
 			}
+			if spec := keyDetails.KeyMetadata.KeySpec; spec != "" && string(spec) != keySpec {
+				return "", fmt.Errorf(
+					"there is a KMS key in %s with a matching alias, but its key spec is %s rather "+
+						"than %s. Please use a different --label for keys of a different key spec.",
+					region, spec, keySpec)
+			}
 			return *aliasRecord.AliasArn, nil
 		}
 	}
 	return "", nil
 }
 
-func (w *kmsInit) discoverOrCreateKeys(ctx context.Context) (map[string]string, error) {
+// discoverOrCreateKeys returns the alias ARN of the key in each region, creating the keys that are
+// missing. With --multi-region-key, it also returns the ARN of the primary key.
+func (w *kmsInit) discoverOrCreateKeys(ctx context.Context) (map[string]string, string, error) {
 	fmt.Printf("Checking %s for the '%s' label.\n",
 		stringsFunc.FriendlyJoin(*w.regions),
 		*w.label)
@@ -241,12 +291,12 @@ func (w *kmsInit) discoverOrCreateKeys(ctx context.Context) (map[string]string,
 	aliasName := kmsAliasName(*w.label)
 	stackName := cfStackName(*w.label)
 
-	existingAliases, regionsMissingKeys, err := collectRegionInfo(ctx, stackName, aliasName, *w.regions)
+	existingAliases, regionsMissingKeys, err := collectRegionInfo(ctx, stackName, aliasName, *w.keySpec, *w.regions)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if len(existingAliases) > 0 && len(regionsMissingKeys) > 0 && !*w.createMissingKeys {
-		return nil, fmt.Errorf("You've requested to use %d regions, but %d regions already "+
+		return nil, "", fmt.Errorf("You've requested to use %d regions, but %d regions already "+
 			"have keys provisioned for "+
 			"label '%s'. If you'd like the additional regions to be provisioned, re-run "+
 			"this command with the --create-missing-keys flag. If you'd like to use a new set of keys, "+
@@ -259,15 +309,35 @@ func (w *kmsInit) discoverOrCreateKeys(ctx context.Context) (map[string]string,
 	if len(existingAliases) > 0 {
 		fmt.Printf("Found %d pre-existing keys.\n", len(existingAliases))
 	}
+	var primaryKeyArn string
+	if *w.multiRegionKey && len(existingAliases) > 0 {
+		if primaryKeyArn, err = findPrimaryKey(ctx, existingAliases); err != nil {
+			return nil, "", err
+		}
+	}
 	if len(existingAliases) == 0 || *w.createMissingKeys {
 		finalAdminArns, finalUserArns, err := w.constructArns(ctx)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		fmt.Printf("%s %s need to be provisioned.\n", stringsFunc.Pluralize("Region", len(regionsMissingKeys)),
 			stringsFunc.FriendlyJoin(regionsMissingKeys))
 
+		// The primary key must exist before it can be replicated to the other regions. As no region
+		// has a key yet, the first region is the first of the missing ones.
+		if *w.multiRegionKey && primaryKeyArn == "" {
+			region := regionsMissingKeys[0]
+			fmt.Printf("%s: Creating the multi-Region primary key using CloudFormation. This may take a "+
+				"while.\n", region)
+			existingAliases[region], primaryKeyArn, err = w.createKeyInRegion(ctx, region, stackName,
+				aliasName, finalAdminArns, finalUserArns, "")
+			if err != nil {
+				return nil, "", fmt.Errorf("%s: %s", region, err)
+			}
+			regionsMissingKeys = regionsMissingKeys[1:]
+		}
+
 		errs := make(chan error, len(regionsMissingKeys))
 		var wg sync.WaitGroup
 		for _, region := range regionsMissingKeys {
@@ -276,8 +346,8 @@ func (w *kmsInit) discoverOrCreateKeys(ctx context.Context) (map[string]string,
 				defer wg.Done()
 				started := time.Now()
 				fmt.Printf("%s: Creating resources using CloudFormation. This may take a while.\n", region)
-				existingAliases[region], err = w.createKeyInRegion(ctx, region, stackName,
-					aliasName, finalAdminArns, finalUserArns)
+				existingAliases[region], _, err = w.createKeyInRegion(ctx, region, stackName,
+					aliasName, finalAdminArns, finalUserArns, primaryKeyArn)
 				if err != nil {
 					errs <- fmt.Errorf("%s: %s", region, err)
 				}
@@ -290,14 +360,34 @@ func (w *kmsInit) discoverOrCreateKeys(ctx context.Context) (map[string]string,
 			fmt.Fprintf(os.Stderr, "%s\n", err)
 		}
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 	}
-	return existingAliases, nil
+	return existingAliases, primaryKeyArn, nil
+}
+
+// findPrimaryKey returns the ARN of the multi-Region primary key that the keys behind aliases are
+// replicas of.
+func findPrimaryKey(ctx context.Context, aliases map[string]string) (string, error) {
+	region := sortedKeys(aliases)[0]
+	cfg := myAWS.MustNewConfig(ctx, config.WithRegion(region))
+	output, err := kms.NewFromConfig(cfg).DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(aliases[region])})
+	if err != nil {
+		return "", err
+	}
+	metadata := output.KeyMetadata
+	if !aws.ToBool(metadata.MultiRegion) || metadata.MultiRegionConfiguration == nil ||
+		metadata.MultiRegionConfiguration.PrimaryKey == nil {
+		return "", fmt.Errorf("The existing key %s is not a multi-Region key. Please use a different "+
+			"--label for multi-Region keys.", aliases[region])
+	}
+	return aws.ToString(metadata.MultiRegionConfiguration.PrimaryKey.Arn), nil
 }
 
-// createKeyInRegion creates a key for a region and returns the Alias's ARN.
-func (w *kmsInit) createKeyInRegion(ctx context.Context, region, stackName, aliasName string, finalAdminArns, finalUserArns []string) (string, error) {
+// createKeyInRegion creates a key for a region and returns the Alias's ARN and the key's ARN. With
+// --multi-region-key, the key is a replica of primaryKeyArn, or the primary key if primaryKeyArn is
+// empty.
+func (w *kmsInit) createKeyInRegion(ctx context.Context, region, stackName, aliasName string, finalAdminArns, finalUserArns []string, primaryKeyArn string) (string, string, error) {
 	specs := cloudformationStack{
 		params: []types.Parameter{
 			{ParameterKey: aws.String("AdministratorPrincipals"), ParameterValue: aws.String(strings.Join(finalAdminArns, ","))},
@@ -309,6 +399,20 @@ func (w *kmsInit) createKeyInRegion(ctx context.Context, region, stackName, alia
 		region:    region,
 		stackName: stackName,
 	}
+	// These parameters are only passed when needed, so that templates given with
+	// --cloudformation-template-url need not declare them. A replica inherits its key spec from the
+	// primary key.
+	if primaryKeyArn != "" {
+		specs.params = append(specs.params, types.Parameter{ParameterKey: aws.String("PrimaryKeyArn"),
+			ParameterValue: aws.String(primaryKeyArn)})
+	} else if *w.keySpec != string(kmstypes.KeySpecSymmetricDefault) {
+		specs.params = append(specs.params, types.Parameter{ParameterKey: aws.String("KeySpec"),
+			ParameterValue: w.keySpec})
+	}
+	if *w.multiRegionKey && primaryKeyArn == "" {
+		specs.params = append(specs.params, types.Parameter{ParameterKey: aws.String("MultiRegion"),
+			ParameterValue: aws.String("true")})
+	}
 	if len(*w.cloudformationTemplateURL) > 0 {
 		specs.templateURL = w.cloudformationTemplateURL
 	} else {
@@ -316,15 +420,15 @@ func (w *kmsInit) createKeyInRegion(ctx context.Context, region, stackName, alia
 	}
 	outputs, err := specs.createAndWait(ctx)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	keyArn := outputs["KeyArn"]
 	if keyArn == "" {
-		return "", fmt.Errorf("Stack %s does not have an Output named KeyArn.", stackName)
+		return "", "", fmt.Errorf("Stack %s does not have an Output named KeyArn.", stackName)
 	}
 
 	aliasARN, err := createAlias(ctx, region, aliasName, keyArn)
-	return aliasARN, err
+	return aliasARN, keyArn, err
 }
 
 func createAlias(ctx context.Context, region, aliasName, keyArn string) (string, error) {
@@ -379,6 +483,15 @@ func (w *kmsInit) constructArns(ctx context.Context) ([]string, []string, error)
 	return adminArns, userArns, nil
 }
 
+func sortedKeys(input map[string]string) []string {
+	results := []string{}
+	for key := range input {
+		results = append(results, key)
+	}
+	sort.Strings(results)
+	return results
+}
+
 func stringStringMapValues(input map[string]string) []string {
 	results := []string{}
 	for _, value := range input {
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/period"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type checkExpiry struct {
+	within   *string
+	names    *[]string
+	filename *string
+}
+
+// NewCheckExpiry configures the command to report secrets that have expired or are due for rotation.
+func NewCheckExpiry(c *kingpin.CmdClause) shared.Command {
+	return &checkExpiry{
+		within: c.Flag("within", "Also report secrets that will expire or be due for rotation within "+
+			"this period.").Default("14d").PlaceHolder("PERIOD").String(),
+		names:    c.Arg("name", "Names of the secrets to check. If not set, all secrets are checked.").Strings(),
+		filename: shared.FilenameFlag(c),
+	}
+}
+
+// deadline is a time by which a secret should be replaced.
+type deadline struct {
+	name string
+	// overdue and soon describe the deadline once it has passed, and before.
+	overdue, soon string
+	due           time.Time
+}
+
+// Run runs the command. It reads only the metadata of each secret, so it needs no access to keys.
+func (r *checkExpiry) Run(ctx context.Context) error {
+	within, err := period.Parse(*r.within)
+	if err != nil {
+		return err
+	}
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.names)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var late []deadline
+	for _, name := range names {
+		for _, d := range deadlines(name, entries[name].Metadata()) {
+			if d.due.Before(now.Add(within)) {
+				late = append(late, d)
+			}
+		}
+	}
+	if len(late) == 0 {
+		return nil
+	}
+	sort.SliceStable(late, func(i, j int) bool { return late[i].due.Before(late[j].due) })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tDUE")
+	for _, d := range late {
+		status := d.soon
+		if !d.due.After(now) {
+			status = d.overdue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.name, status, formatTime(d.due))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	lateNames := make(map[string]bool)
+	for _, d := range late {
+		lateNames[d.name] = true
+	}
+	return fmt.Errorf("%d %s must be replaced within %s.", len(lateNames),
+		stringsFunc.Pluralize("secret", len(lateNames)), *r.within)
+}
+
+// deadlines returns when a secret expires and when it is due for rotation, if it does. Rotation is
+// due a period after the plaintext last changed, so re-encrypting a secret does not put it off.
+func deadlines(name string, metadata store.Metadata) []deadline {
+	var found []deadline
+	if !metadata.Expires.IsZero() {
+		found = append(found, deadline{name, "expired", "expires soon", metadata.Expires})
+	}
+	if metadata.RotateEvery != "" && !metadata.RotatedAt().IsZero() {
+		every, err := period.Parse(metadata.RotateEvery)
+		if err != nil {
+			// A period that can't be read is treated as already due, so that it gets looked at.
+			every = 0
+		}
+		found = append(found, deadline{name, "rotation overdue", "rotation due soon",
+			metadata.RotatedAt().Add(every)})
+	}
+	return found
+}
+
+// parseExpiry returns the time given by s, which is either a date such as 2030-01-31, a time in
+// RFC 3339 format, or a period such as 90d counted from now.
+func parseExpiry(s string, now time.Time) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if expires, err := time.Parse(layout, s); err == nil {
+			return expires.UTC(), nil
+		}
+	}
+	after, err := period.Parse(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return now.Add(after).UTC().Truncate(time.Second), nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var errRefusingToDeleteTemplate = errors.New("Refusing to delete the " + store.KeyTemplateName + " entry. " +
+	"Pass --allow-template if you really want to remove it.")
+
+type del struct {
+	names         *[]string
+	allowTemplate *bool
+	filename      *string
+}
+
+// NewDelete configures the command to delete secrets.
+func NewDelete(c *kingpin.CmdClause) shared.Command {
+	return &del{
+		names: c.Arg("name", "Names of the secrets to delete.").Required().Strings(),
+		allowTemplate: c.Flag("allow-template", "Allow the "+store.KeyTemplateName+" entry to be "+
+			"deleted.").Bool(),
+		filename: shared.FilenameFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *del) Run(ctx context.Context) error {
+	for _, name := range *r.names {
+		if name == store.KeyTemplateName && !*r.allowTemplate {
+			return errRefusingToDeleteTemplate
+		}
+	}
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
+	return database.Delete(*r.names...)
+}
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type edit struct {
+	name           *string
+	filename       *string
+	regionPriority *[]string
+}
+
+// NewEdit configures the command to edit a secret in an editor.
+func NewEdit(c *kingpin.CmdClause) shared.Command {
+	return &edit{
+		name:           c.Arg("name", "Name of the secret to edit.").Required().String(),
+		filename:       shared.FilenameFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+	}
+}
+
+// Run the command.
+func (r *edit) Run(ctx context.Context) error {
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
+	values, err := database.Get(*r.name)
+	if err != nil {
+		return err
+	}
+	var keys []store.Key
+	for _, value := range values {
+		keys = append(keys, value.Key)
+	}
+	plaintext, err := decryptFirst(ctx, values, *r.name, *r.regionPriority)
+	if err != nil {
+		return err
+	}
+
+	edited, err := editPrivately(plaintext)
+	if err != nil {
+		return err
+	}
+	// Most editors add a newline to the end of the file when saving it.
+	if !bytes.HasSuffix(plaintext, []byte("\n")) {
+		edited = bytes.TrimSuffix(edited, []byte("\n"))
+	}
+	if bytes.Equal(plaintext, edited) {
+		fmt.Printf("No change: %s was not modified.\n", *r.name)
+		return nil
+	}
+
+	updated, err := encryptAll(ctx, keys, *r.name, edited, author(ctx, keys))
+	if err != nil {
+		return err
+	}
+	updated = store.InheritMetadata(updated, values, store.Metadata{})
+	// Refuse to overwrite changes that were made while the editor was open.
+	if err := database.CompareAndSwap(*r.name, values, updated); err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			return fmt.Errorf("%w; your edits were not saved", err)
+		}
+		return err
+	}
+	fmt.Printf("Saved %s.\n", *r.name)
+	return nil
+}
+
+// editPrivately opens contents in the user's editor and returns the saved result. The file is
+// created with mode 0600 in a new directory on a memory-backed filesystem when one is available. It
+// is overwritten with zeros and removed before editPrivately returns.
+//
+// While the editor runs, an interrupt is left to the editor, which shares the terminal, as git and
+// sudoedit do. A SIGTERM or SIGHUP is passed on to the editor, and the file is removed once the
+// editor has exited.
+func editPrivately(contents []byte) ([]byte, error) {
+	editor, err := shared.FindEditor()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(privateTempDir(), shared.ProgName+"-edit-")
+	if err != nil {
+		return nil, err
+	}
+	filename := filepath.Join(dir, "secret")
+	defer func() {
+		scrub(filename)
+		os.RemoveAll(dir)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(contents); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(editor, filename)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	var received os.Signal
+	for {
+		select {
+		case sig := <-signals:
+			if sig == os.Interrupt {
+				continue
+			}
+			received = sig
+			if err := cmd.Process.Signal(sig); err != nil {
+				cmd.Process.Kill()
+			}
+		case err := <-exited:
+			if received != nil {
+				return nil, fmt.Errorf("%s: your edits were not saved", received)
+			}
+			if err != nil {
+				return nil, err
+			}
+			return os.ReadFile(filename)
+		}
+	}
+}
+
+// privateTempDir returns a directory for temporary files that is backed by memory if possible.
+func privateTempDir() string {
+	for _, candidate := range []string{os.Getenv("XDG_RUNTIME_DIR"), "/dev/shm"} {
+		if candidate == "" {
+			continue
+		}
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate
+		}
+	}
+	return os.TempDir()
+}
+
+// scrub overwrites a file with zeros so that its contents do not linger on disk after removal.
+func scrub(filename string) {
+	f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if _, err := f.Write(make([]byte, info.Size())); err != nil {
+		return
+	}
+	f.Sync()
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/envvar"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type execute struct {
+	filename       *string
+	regionPriority *[]string
+	only           *[]string
+	prefix         *string
+	command        *[]string
+}
+
+// NewExec configures the command that runs a program with secrets in its environment.
+func NewExec(c *kingpin.CmdClause) shared.Command {
+	only := c.Flag("only", "Comma-delimited list of secrets to decrypt. Defaults to all secrets.").
+		PlaceHolder("NAME,...")
+	onlyValue := (&shared.CommaSeparatedList{}).Name("only")
+	only.SetValue(onlyValue)
+	return &execute{
+		filename:       shared.FilenameFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+		only:           &onlyValue.V,
+		prefix: c.Flag("prefix", "Prefix added to the name of each environment variable.").
+			PlaceHolder("PREFIX").
+			String(),
+		command: c.Arg("command", "The program to run, followed by its arguments. Use -- to separate "+
+			"the program's arguments from biscuit's flags.").Required().Strings(),
+	}
+}
+
+// Run replaces the biscuit process with the requested program, or on Windows runs it and exits with
+// its exit code. On success, Run does not return.
+func (r *execute) Run(ctx context.Context) error {
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.only)
+	if err != nil {
+		return err
+	}
+
+	variables := make(map[string]string)
+	secretForVariable := make(map[string]string)
+	for _, name := range names {
+		variable := envvar.Name(*r.prefix, name)
+		if other, present := secretForVariable[variable]; present {
+			return fmt.Errorf("secrets %s and %s would both be stored in $%s", other, name, variable)
+		}
+		secretForVariable[variable] = name
+		plaintext, err := decryptFirst(ctx, entries[name], name, *r.regionPriority)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		variables[variable] = string(plaintext)
+	}
+
+	environment := make([]string, 0, len(os.Environ())+len(variables))
+	for _, kv := range os.Environ() {
+		if _, overridden := variables[envName(kv)]; !overridden {
+			environment = append(environment, kv)
+		}
+	}
+	for variable, value := range variables {
+		environment = append(environment, variable+"="+value)
+	}
+
+	program, err := exec.LookPath((*r.command)[0])
+	if err != nil {
+		return err
+	}
+	return execProgram(program, *r.command, environment)
+}
+
+func envName(kv string) string {
+	if i := strings.IndexByte(kv, '='); i >= 0 {
+		return kv[:i]
+	}
+	return kv
+}
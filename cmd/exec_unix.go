@@ -0,0 +1,13 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import "syscall"
+
+// execProgram replaces the biscuit process with program. Exec keeps our PID, so the program inherits
+// stdin, receives signals sent to biscuit, and its exit status is reported to our parent directly.
+// On success, execProgram does not return.
+func execProgram(program string, args, environment []string) error {
+	return syscall.Exec(program, args, environment)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+)
+
+// execProgram runs program as a child process, because Windows cannot replace a running process,
+// and exits with its exit code. On success, execProgram does not return.
+func execProgram(program string, args, environment []string) error {
+	cmd := exec.Command(program, args[1:]...)
+	cmd.Env = environment
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		for sig := range signals {
+			// The console sends Ctrl-C and Ctrl-Break to the program as well, so biscuit only has
+			// to wait for it. Windows cannot deliver other signals, so the program is stopped.
+			if sig != os.Interrupt {
+				cmd.Process.Kill()
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -1,13 +1,18 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"os"
+	"path"
+	"strings"
 
+	"github.com/dcoker/biscuit/client"
 	"github.com/dcoker/biscuit/cmd/internal/shared"
-	"github.com/dcoker/biscuit/internal/yaml"
+	"github.com/dcoker/biscuit/internal/format"
+	"github.com/dcoker/biscuit/keymanager"
 	"github.com/dcoker/biscuit/store"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
@@ -15,6 +20,10 @@ import (
 type export struct {
 	filename       *string
 	regionPriority *[]string
+	format         *string
+	only           *[]string
+	exclude        *[]string
+	writeTo        *string
 }
 
 // NewExport configures the flags for export.
@@ -22,36 +31,118 @@ func NewExport(c *kingpin.CmdClause) shared.Command {
 	return &export{
 		filename:       shared.FilenameFlag(c),
 		regionPriority: shared.AwsRegionPriorityFlag(c),
+		format: c.Flag("format", "Output format. The dotenv and shell formats convert names into "+
+			"environment variable names. Options: "+strings.Join(format.Names(), ", ")).
+			Default(format.YAML).
+			Enum(format.Names()...),
+		only: c.Flag("only", "Only export secrets whose names match the glob PATTERN. May be repeated.").
+			PlaceHolder("PATTERN").
+			Strings(),
+		exclude: c.Flag("exclude", "Do not export secrets whose names match the glob PATTERN. May be "+
+			"repeated.").
+			PlaceHolder("PATTERN").
+			Strings(),
+		writeTo: c.Flag("output", "Write to FILE instead of stdout. FILE will be readable only by "+
+			"the current user.").
+			PlaceHolder("FILE").
+			Short('o').
+			String(),
 	}
 }
 
 // Run the command.
 func (r *export) Run(ctx context.Context) error {
-	database := store.NewFileStore(*r.filename)
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
 	entries, err := database.GetAll()
 	if err != nil {
 		return err
 	}
+	ctx = keymanager.WithRegionPriority(ctx, *r.regionPriority)
+	secrets := make(map[string][]byte)
 	errs := 0
 	for name, values := range entries {
 		if name == store.KeyTemplateName {
 			continue
 		}
+		selected, err := r.selected(name)
+		if err != nil {
+			return err
+		}
+		if !selected {
+			continue
+		}
 
 		store.SortByKmsRegion(*r.regionPriority)(values)
 		for _, v := range values {
-			bytes, err := decryptOneValue(ctx, v, name)
+			plaintext, err := client.DecryptValue(ctx, v, name)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: unable to decrypt, skipping: %s\n", err)
 				errs++
 				continue
 			}
-			fmt.Print(yaml.ToString(map[string]string{name: string(bytes)}))
+			secrets[name] = plaintext
 			break
 		}
 	}
+
+	var output bytes.Buffer
+	if err := format.Write(&output, *r.format, secrets); err != nil {
+		return err
+	}
+	if len(*r.writeTo) > 0 {
+		if err := writePrivateFile(*r.writeTo, output.Bytes()); err != nil {
+			return err
+		}
+	} else if _, err := os.Stdout.Write(output.Bytes()); err != nil {
+		return err
+	}
 	if errs > 0 {
 		return errors.New("there were errors exporting")
 	}
 	return nil
 }
+
+// selected reports whether the named secret matches the --only and --exclude patterns.
+func (r *export) selected(name string) (bool, error) {
+	matches := func(patterns []string) (bool, error) {
+		for _, pattern := range patterns {
+			matched, err := path.Match(pattern, name)
+			if err != nil {
+				return false, fmt.Errorf("%s: %w", pattern, err)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if len(*r.only) > 0 {
+		included, err := matches(*r.only)
+		if err != nil || !included {
+			return false, err
+		}
+	}
+	excluded, err := matches(*r.exclude)
+	return !excluded, err
+}
+
+// writePrivateFile writes data to filename, making sure that the file is only readable by the
+// current user even if it already existed.
+func writePrivateFile(filename string, data []byte) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/client"
 	"github.com/dcoker/biscuit/cmd/internal/shared"
 	"github.com/dcoker/biscuit/keymanager"
 	"github.com/dcoker/biscuit/store"
@@ -18,6 +18,7 @@ type get struct {
 	writeTo        *string
 	filename       *string
 	regionPriority *[]string
+	version        *int
 }
 
 // NewGet constructs the command to decrypt an encrypted value.
@@ -30,31 +31,24 @@ func NewGet(c *kingpin.CmdClause) shared.Command {
 			Short('o').
 			String(),
 		filename: shared.FilenameFlag(c),
+		version: c.Flag("at-version", "Read version N of the secret instead of the latest. Only storage "+
+			"backends that keep previous versions support this.").
+			PlaceHolder("N").
+			Int(),
 	}
 }
 
 // Run the command.
 func (r *get) Run(ctx context.Context) error {
-	database := store.NewFileStore(*r.filename)
-	values, err := database.Get(*r.name)
+	database, err := store.Open(ctx, *r.filename)
 	if err != nil {
 		return err
 	}
-	store.SortByKmsRegion(*r.regionPriority)(values)
-	// There may be multiple values, but we assume that each one represents the same contents
-	// so we stop after processing just one successfully.
-	var plaintext []byte
-	for _, value := range values {
-		plaintext, err = decryptOneValue(ctx, value, *r.name)
-		if err != nil {
-			fmt.Fprintf(os.Stderr,
-				"Warning: decryption under %s failed: %s\n",
-				value.KeyManager,
-				err)
-			continue
-		}
-		break
+	values, err := getVersion(database, *r.name, *r.version)
+	if err != nil {
+		return err
 	}
+	plaintext, err := decryptFirst(ctx, values, *r.name, *r.regionPriority)
 	if err != nil {
 		return err
 	}
@@ -70,38 +64,39 @@ func (r *get) Run(ctx context.Context) error {
 	return nil
 }
 
-func decryptOneValue(ctx context.Context, value store.Value, name string) ([]byte, error) {
-	algo, err := algorithms.Get(value.Algorithm)
-	if err != nil {
-		return []byte{}, err
+// getVersion returns a specific version of a secret, or the latest version if version is zero.
+func getVersion(database store.Store, name string, version int) (store.ValueList, error) {
+	if version == 0 {
+		return database.Get(name)
 	}
-	var keyPlaintext []byte
-	if algo.NeedsKey() {
-		keyPlaintext, err = getPlaintextKeyFromManager(ctx, value, name)
-		if err != nil {
-			return nil, err
-		}
-	}
-	decoded, err := value.GetCiphertext()
+	versioned, err := asVersioned(database)
 	if err != nil {
-		return []byte{}, err
+		return nil, err
 	}
-	plaintext, err := algo.Decrypt(keyPlaintext, decoded)
-	return plaintext, err
+	return versioned.GetVersion(name, version)
 }
 
-func getPlaintextKeyFromManager(ctx context.Context, value store.Value, name string) ([]byte, error) {
-	keyManager, err := keymanager.New(value.KeyManager)
-	if err != nil {
-		return []byte{}, err
-	}
-	keyCiphertext, err := value.GetKeyCiphertext()
-	if err != nil {
-		return []byte{}, err
-	}
-	keyPlaintext, err := keyManager.Decrypt(ctx, value.Key.KeyID, keyCiphertext, name)
-	if err != nil {
-		return []byte{}, err
+// decryptFirst returns the plaintext of the first value that can be decrypted, trying values in the
+// regions listed in regionPriority first.
+func decryptFirst(ctx context.Context, values store.ValueList, name string, regionPriority []string) ([]byte, error) {
+	// Sort a copy so that the caller's values are left in their stored order.
+	values = append(store.ValueList(nil), values...)
+	store.SortByKmsRegion(regionPriority)(values)
+	ctx = keymanager.WithRegionPriority(ctx, regionPriority)
+	// There may be multiple values, but we assume that each one represents the same contents
+	// so we stop after processing just one successfully.
+	var plaintext []byte
+	var err error
+	for _, value := range values {
+		plaintext, err = client.DecryptValue(ctx, value, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr,
+				"Warning: decryption under %s failed: %s\n",
+				value.KeyManager,
+				err)
+			continue
+		}
+		break
 	}
-	return keyPlaintext, nil
+	return plaintext, err
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var errVersionsNotSupported = errors.New("This storage backend does not keep previous versions of secrets.")
+
+type history struct {
+	name     *string
+	filename *string
+}
+
+// NewHistory configures the command to list the versions of a secret.
+func NewHistory(c *kingpin.CmdClause) shared.Command {
+	return &history{
+		name:     shared.SecretNameArg(c),
+		filename: shared.FilenameFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *history) Run(ctx context.Context) error {
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
+	versioned, err := asVersioned(database)
+	if err != nil {
+		return err
+	}
+	versions, err := versioned.History(*r.name)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tUPDATED\tAUTHOR")
+	for i, values := range versions {
+		version := fmt.Sprint(values.Version())
+		if i == len(versions)-1 {
+			version += " (current)"
+		}
+		var metadata store.Metadata
+		if len(values) > 0 {
+			metadata = values[0].Metadata
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", version, formatTime(metadata.Updated), orDash(metadata.Author))
+	}
+	return w.Flush()
+}
+
+// asVersioned returns database as a store.Versioned, or an error if it does not keep versions.
+func asVersioned(database store.Store) (store.Versioned, error) {
+	versioned, ok := database.(store.Versioned)
+	if !ok {
+		return nil, errVersionsNotSupported
+	}
+	return versioned, nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/format"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	errConflictingImportModes = errors.New("Please specify either --overwrite or --skip-existing, but not both.")
+)
+
+type importSecrets struct {
+	keyFlags
+	from         *string
+	source       *string
+	overwrite    *bool
+	skipExisting *bool
+	filename     *string
+}
+
+// NewImport configures the command that encrypts secrets from a plaintext file.
+func NewImport(c *kingpin.CmdClause) shared.Command {
+	params := &importSecrets{}
+	params.keyFlags = newKeyFlags(c)
+	params.from = c.Flag("from", "Format of SOURCE. Options: "+strings.Join(format.ReadableNames(), ", ")).
+		Required().
+		Enum(format.ReadableNames()...)
+	params.overwrite = c.Flag("overwrite", "Replace secrets that already exist in FILE.").Bool()
+	params.skipExisting = c.Flag("skip-existing", "Leave secrets that already exist in FILE "+
+		"unchanged.").Bool()
+	params.source = c.Arg("source", "File containing the plaintext secrets.").
+		Required().
+		String()
+	params.filename = shared.FilenameFlag(c)
+	return params
+}
+
+// Run runs the command.
+func (w *importSecrets) Run(ctx context.Context) error {
+	if *w.overwrite && *w.skipExisting {
+		return errConflictingImportModes
+	}
+	secrets, err := w.readSource()
+	if err != nil {
+		return err
+	}
+
+	database, err := store.Open(ctx, *w.filename)
+	if err != nil {
+		return err
+	}
+	keys, err := w.chooseKeys(database)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	newFile := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !newFile {
+		return err
+	}
+
+	var names, existing []string
+	for name := range secrets {
+		if name == store.KeyTemplateName {
+			return fmt.Errorf("%s is reserved and cannot be imported", store.KeyTemplateName)
+		}
+		if _, present := entries[name]; present {
+			existing = append(existing, name)
+			if *w.skipExisting {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(existing) > 0 && !*w.overwrite && !*w.skipExisting {
+		return fmt.Errorf("%s already %s. Use --overwrite or --skip-existing.",
+			stringsFunc.FriendlyJoin(existing), pluralizeExist(len(existing)))
+	}
+
+	updates := make(store.EntryMap)
+	writer := author(ctx, keys)
+	for _, name := range names {
+		updates[name], err = encryptAll(ctx, keys, name, secrets[name], writer)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	err = database.Update(func(current store.EntryMap) error {
+		// If the file doesn't have a template, create one from the keys used here.
+		if len(current) == 0 {
+			current[store.KeyTemplateName] = templateFromKeys(keys)
+		}
+		// Another writer may have added some of these names since we checked.
+		for name, values := range updates {
+			if _, present := current[name]; present && !*w.overwrite {
+				if _, existed := entries[name]; !existed {
+					return fmt.Errorf("%s: %w", name, store.ErrConflict)
+				}
+			}
+			current[name] = store.InheritMetadata(values, current[name], store.Metadata{})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Imported %d %s", len(names), stringsFunc.Pluralize("secret", len(names)))
+	if *w.skipExisting && len(existing) > 0 {
+		fmt.Printf(", skipped %d", len(existing))
+	}
+	fmt.Printf(".\n")
+	return nil
+}
+
+func (w *importSecrets) readSource() (map[string][]byte, error) {
+	f, err := os.Open(*w.source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return format.Read(f, *w.from)
+}
+
+func pluralizeExist(count int) string {
+	if count > 1 {
+		return "exist"
+	}
+	return "exists"
+}
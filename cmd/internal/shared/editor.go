@@ -0,0 +1,21 @@
+package shared
+
+import (
+	"errors"
+	"os"
+)
+
+var (
+	errNoEditorFound = errors.New("Set your editor preference with VISUAL or EDITOR environment variables.")
+)
+
+// FindEditor returns the user's preferred editor from the VISUAL or EDITOR environment variables.
+func FindEditor() (string, error) {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		candidate := os.Getenv(name)
+		if len(candidate) > 0 {
+			return candidate, nil
+		}
+	}
+	return "", errNoEditorFound
+}
@@ -81,6 +81,7 @@ type StringValue struct {
 	minLength,
 	maxLength *int
 	regex *regexp.Regexp
+	check func(string) error
 }
 
 // Set is called by the flag parser.
@@ -98,6 +99,11 @@ func (c *StringValue) Set(input string) error {
 	if c.regex != nil && !c.regex.MatchString(c.v) {
 		return fmt.Errorf("%smust satisfy regex %s", c.name, c.regex.String())
 	}
+	if c.check != nil {
+		if err := c.check(c.v); err != nil {
+			return fmt.Errorf("%s%s", c.name, err)
+		}
+	}
 	return nil
 }
 
@@ -130,6 +136,12 @@ func (c *StringValue) Regex(s string) *StringValue {
 	return c
 }
 
+// Check validates the value with a function.
+func (c *StringValue) Check(f func(string) error) *StringValue {
+	c.check = f
+	return c
+}
+
 // Trimmed removes whitespace from the value before validation.
 func (c *StringValue) Trimmed() *StringValue {
 	c.trim = true
@@ -155,8 +167,9 @@ func AlgorithmFlag(cc *kingpin.CmdClause) *string {
 
 // FilenameFlag defines a flag for the filename.
 func FilenameFlag(cc *kingpin.CmdClause) *string {
-	return cc.Flag("filename", "Name of file storing the secrets. If the environment variable BISCUIT_FILENAME "+
-		"is set, it will be used as the default value.").
+	return cc.Flag("filename", "Name of file storing the secrets, or a URL such as file:///path/to/secrets.yml "+
+		"selecting a storage backend. If the environment variable BISCUIT_FILENAME is set, it will be used as "+
+		"the default value.").
 		PlaceHolder("FILE").
 		Envar("BISCUIT_FILENAME").
 		Short('f').
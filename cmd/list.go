@@ -3,6 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/dcoker/biscuit/cmd/internal/shared"
 	"github.com/dcoker/biscuit/store"
@@ -10,27 +14,85 @@ import (
 )
 
 type list struct {
+	long     *bool
 	filename *string
 }
 
 // NewList configures the command to list secrets.
 func NewList(c *kingpin.CmdClause) shared.Command {
-	return &list{filename: shared.FilenameFlag(c)}
+	return &list{
+		long: c.Flag("long", "Also show the version, when each secret was created and last updated, who "+
+			"updated it, and its description and tags.").Short('l').Bool(),
+		filename: shared.FilenameFlag(c),
+	}
 }
 
 // Run runs the command.
 func (r *list) Run(ctx context.Context) error {
-	database := store.NewFileStore(*r.filename)
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
 
 	entries, err := database.GetAll()
 	if err != nil {
 		return err
 	}
-	for name := range entries {
-		if name == store.KeyTemplateName {
-			continue
+	names, err := selectNames(entries, nil)
+	if err != nil {
+		return err
+	}
+	if !*r.long {
+		for _, name := range names {
+			fmt.Printf("%s\n", name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tCREATED\tUPDATED\tAUTHOR\tDESCRIPTION\tTAGS")
+	for _, name := range names {
+		metadata := entries[name].Metadata()
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			name,
+			entries[name].Version(),
+			formatTime(metadata.Created),
+			formatTime(metadata.Updated),
+			orDash(metadata.Author),
+			orDash(metadata.Description),
+			orDash(formatTags(metadata.Tags)))
+	}
+	return w.Flush()
+}
+
+// formatTags returns tags as KEY=VALUE pairs separated by commas, sorted by key.
+func formatTags(tags map[string]string) string {
+	var pairs []string
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// selectNames returns the sorted names of the secrets in entries, excluding the template. If only is
+// not empty, only those names are returned and each of them must be present.
+func selectNames(entries store.EntryMap, only []string) ([]string, error) {
+	var names []string
+	if len(only) > 0 {
+		for _, name := range only {
+			if _, present := entries[name]; !present || name == store.KeyTemplateName {
+				return nil, fmt.Errorf("%s: %w", name, store.ErrNameNotFound)
+			}
+			names = append(names, name)
+		}
+	} else {
+		for name := range entries {
+			if name != store.KeyTemplateName {
+				names = append(names, name)
+			}
 		}
-		fmt.Printf("%s\n", name)
 	}
-	return nil
+	sort.Strings(names)
+	return names, nil
 }
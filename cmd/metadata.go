@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	myAWS "github.com/dcoker/biscuit/internal/aws"
+	"github.com/dcoker/biscuit/internal/aws/arn"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+)
+
+// newMetadata describes a version of a secret with a new plaintext that is being written now by
+// writer, as returned by author. The Store assigns the version number.
+func newMetadata(writer string) store.Metadata {
+	now := time.Now().UTC().Truncate(time.Second)
+	return store.Metadata{
+		Updated: now,
+		Changed: now,
+		Author:  writer,
+	}
+}
+
+// author identifies the current user. If any of the keys are under KMS, AWS credentials are at hand,
+// so the author is the ARN of the caller's AWS identity. Otherwise, or if that fails, it is
+// user@host. Commands call it once, as it may call STS.
+func author(ctx context.Context, keys []store.Key) string {
+	for _, key := range keys {
+		if key.KeyManager != keymanager.KmsLabel {
+			continue
+		}
+		if identity, err := callerIdentity(ctx, key.KeyID); err == nil {
+			return identity
+		}
+		break
+	}
+	return localUser()
+}
+
+// callerIdentity returns the ARN of the AWS identity, asking STS in the region of keyID.
+func callerIdentity(ctx context.Context, keyID string) (string, error) {
+	cfg, err := myAWS.NewConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+	if parsed, err := arn.New(keyID); err == nil {
+		cfg.Region = parsed.Region
+	}
+	output, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(output.Arn), nil
+}
+
+func localUser() string {
+	name := "unknown"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		return name + "@" + host
+	}
+	return name
+}
@@ -2,17 +2,18 @@ package cmd
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"io"
-	"io/fs"
 	"os"
 	"strings"
 
 	"sync"
+	"time"
 
 	"github.com/dcoker/biscuit/algorithms"
+	"github.com/dcoker/biscuit/client"
 	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/internal/period"
 	"github.com/dcoker/biscuit/keymanager"
 	"github.com/dcoker/biscuit/store"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -20,13 +21,23 @@ import (
 
 // Put implements the "put" command.
 type put struct {
+	keyFlags
+	name        *string
+	fromFile    **os.File
+	value       *string
+	description *string
+	tags        *map[string]string
+	expires     *string
+	rotateEvery *string
+	clearExpiry *bool
+	filename    *string
+}
+
+// keyFlags are the flags that choose the keys that new secrets are encrypted under.
+type keyFlags struct {
 	keyID      *string
 	keyManager *string
-	name       *string
-	fromFile   **os.File
-	value      *string
 	algo       *string
-	filename   *string
 }
 
 var (
@@ -35,29 +46,54 @@ var (
 	errConflictingValue = errors.New(
 		"Please specify either a secret in a positional argument, or use --from-file, " +
 			"but not both.")
+	errConflictingExpiry = errors.New("Please specify either --clear-expiry, or --expires and " +
+		"--rotate-every, but not both.")
 )
 
 // NewPut configures the command for storing secrets.
 func NewPut(c *kingpin.CmdClause) shared.Command {
 	write := &put{}
-	write.keyID = c.Flag("key-id",
-		"The ID of the key to use. This can be a full key ARN, or just the alias/ or the key ID (if "+
-			"AWS_REGION is set). If --key-id is not set, the "+store.KeyTemplateName+" "+
-			"entry from FILE will be used "+
-			"(if present).").Short('k').String()
-	write.keyManager = c.Flag("key-manager", "Source of envelope encryption keys. Options: "+
-		strings.Join(keymanager.GetKeyManagers(), ", ")).
-		Default(keymanager.GetDefaultKeyManager()).Short('p').Enum(keymanager.GetKeyManagers()...)
+	write.keyFlags = newKeyFlags(c)
 	write.name = c.Arg("name", "Name of the secret.").Required().String()
 	write.value = c.Arg("secret", "Value of the secret.").String()
 	write.fromFile = c.Flag("from-file", "Read the secret from FILE instead "+
 		"of the command line.").PlaceHolder("FILE").Short('i').File()
-	write.algo = shared.AlgorithmFlag(c)
+	write.description = c.Flag("description", "Describe what the secret is for. If not set, the "+
+		"current description is kept.").String()
+	write.tags = c.Flag("tag", "Label the secret with KEY=VALUE. May be repeated. Tags are added to the "+
+		"current tags.").PlaceHolder("KEY=VALUE").StringMap()
+	write.expires = c.Flag("expires", "Mark the secret as expiring after a period such as 90d, or on "+
+		"a date such as 2030-01-31. The expiry applies to this value only, and is removed when a new "+
+		"value is put.").PlaceHolder("PERIOD").String()
+	write.rotateEvery = c.Flag("rotate-every", "Mark the secret as due for rotation a period such as 90d "+
+		"after each time its value changes. If not set, the current period is kept.").PlaceHolder("PERIOD").String()
+	write.clearExpiry = c.Flag("clear-expiry", "Remove the expiry and rotation period of the secret.").Bool()
 	write.filename = shared.FilenameFlag(c)
 
 	return write
 }
 
+func newKeyFlags(c *kingpin.CmdClause) keyFlags {
+	return keyFlags{
+		keyID: c.Flag("key-id",
+			"The ID of the key to use. This can be a full key ARN, or just the alias/ or the key ID (if "+
+				"AWS_REGION is set). Separate several keys with commas, and prefix a key with MANAGER: "+
+				"to use a key manager other than --key-manager for it, as in "+
+				"arn:aws:kms:...,age:age1.... If --key-id is not set, the "+store.KeyTemplateName+" "+
+				"entry from FILE will be used "+
+				"(if present).").Short('k').String(),
+		keyManager: shared.StringFlag(c.Flag("key-manager", "Source of envelope encryption keys. Options: "+
+			strings.Join(keymanager.GetKeyManagers(), ", ")+", or "+keymanager.PluginPrefix+"NAME to run "+
+			keymanager.PluginCommandPrefix+"NAME.").
+			Default(keymanager.GetDefaultKeyManager()).Short('p'),
+			(&shared.StringValue{}).Name("key-manager").Check(func(label string) error {
+				_, err := keymanager.New(label)
+				return err
+			})),
+		algo: shared.AlgorithmFlag(c),
+	}
+}
+
 type encryptResult struct {
 	value store.Value
 	err   error
@@ -65,7 +101,10 @@ type encryptResult struct {
 
 // Run runs the command.
 func (w *put) Run(ctx context.Context) error {
-	database := store.NewFileStore(*w.filename)
+	database, err := store.Open(ctx, *w.filename)
+	if err != nil {
+		return err
+	}
 
 	keys, err := w.chooseKeys(database)
 	if err != nil {
@@ -77,60 +116,49 @@ func (w *put) Run(ctx context.Context) error {
 		return err
 	}
 
-	results := make(chan encryptResult, len(keys))
-	var wg sync.WaitGroup
-	for _, keyConfig := range keys {
-		wg.Add(1)
-		go func(keyConfig store.Key, plaintext []byte) {
-			defer wg.Done()
-			value, err := encryptOne(ctx, keyConfig, *w.name, plaintext)
-			results <- encryptResult{value, err}
-		}(keyConfig, plaintext)
+	changes, err := w.metadataChanges(time.Now())
+	if err != nil {
+		return err
 	}
-	wg.Wait()
-	close(results)
 
-	var valueList []store.Value
-	for value := range results {
-		if value.err != nil {
-			return value.err
-		}
-		valueList = append(valueList, value.value)
+	valueList, err := encryptAll(ctx, keys, *w.name, plaintext, author(ctx, keys))
+	if err != nil {
+		return err
 	}
 
-	// If the file doesn't have a template, create one from the keys used here.
-	if _, err := database.Get(store.KeyTemplateName); errors.Is(err, fs.ErrNotExist) {
-		var values []store.Value
-		for _, key := range keys {
-			values = append(values, store.Value{Key: key})
+	return database.Update(func(entries store.EntryMap) error {
+		// If the file doesn't have a template, create one from the keys used here.
+		if len(entries) == 0 {
+			entries[store.KeyTemplateName] = templateFromKeys(keys)
 		}
-		err := database.Put(store.KeyTemplateName, values)
-		if err != nil {
-			return err
+		entries[*w.name] = store.InheritMetadata(valueList, entries[*w.name], changes)
+		if *w.clearExpiry {
+			entries[*w.name] = entries[*w.name].WithoutExpiry()
 		}
-	}
-
-	return database.Put(*w.name, valueList)
+		return nil
+	})
 }
 
-func (w *put) chooseKeys(database store.FileStore) ([]store.Key, error) {
-	if len(*w.keyID) > 0 {
+// chooseKeys returns the keys named by --key-id, or the keys in the template if --key-id is not set.
+func (k keyFlags) chooseKeys(database store.Store) ([]store.Key, error) {
+	if len(*k.keyID) > 0 {
 		var keys []store.Key
-		split := strings.Split(*w.keyID, ",")
+		split := strings.Split(*k.keyID, ",")
 		for _, key := range split {
+			manager, keyID := keymanager.SplitLabel(key, *k.keyManager)
 			keys = append(keys, store.Key{
-				KeyManager: *w.keyManager,
-				KeyID:      key,
-				Algorithm:  *w.algo})
+				KeyManager: manager,
+				KeyID:      keyID,
+				Algorithm:  *k.algo})
 		}
 		return keys, nil
 	}
-	algo, err := algorithms.Get(*w.algo)
+	algo, err := algorithms.Get(*k.algo)
 	if err != nil {
 		return nil, err
 	}
 	if !algo.NeedsKey() {
-		return []store.Key{{Algorithm: *w.algo}}, nil
+		return []store.Key{{Algorithm: *k.algo}}, nil
 	}
 	templateKeys, err := database.GetKeyIds()
 	if os.IsNotExist(err) {
@@ -142,6 +170,40 @@ func (w *put) chooseKeys(database store.FileStore) ([]store.Key, error) {
 	return templateKeys, nil
 }
 
+// templateFromKeys returns a template entry that encrypts new secrets under keys.
+func templateFromKeys(keys []store.Key) store.ValueList {
+	var values store.ValueList
+	for _, key := range keys {
+		values = append(values, store.Value{Key: key})
+	}
+	return values
+}
+
+// metadataChanges returns the metadata set by the flags, with an expiry period counted from now.
+func (w *put) metadataChanges(now time.Time) (store.Metadata, error) {
+	if *w.clearExpiry && (*w.expires != "" || *w.rotateEvery != "") {
+		return store.Metadata{}, errConflictingExpiry
+	}
+	changes := store.Metadata{
+		Description: *w.description,
+		Tags:        *w.tags,
+		RotateEvery: *w.rotateEvery,
+	}
+	if *w.expires != "" {
+		expires, err := parseExpiry(*w.expires, now)
+		if err != nil {
+			return changes, err
+		}
+		changes.Expires = expires
+	}
+	if *w.rotateEvery != "" {
+		if _, err := period.Parse(*w.rotateEvery); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
 func (w *put) choosePlaintext() ([]byte, error) {
 	if *w.fromFile != nil && len(*w.value) > 0 {
 		return nil, errConflictingValue
@@ -153,33 +215,32 @@ func (w *put) choosePlaintext() ([]byte, error) {
 	return []byte(*w.value), nil
 }
 
-func encryptOne(ctx context.Context, keyConfig store.Key, name string, plaintext []byte) (store.Value, error) {
-	var value store.Value
-	algo, err := algorithms.Get(keyConfig.Algorithm)
-	if err != nil {
-		return value, err
+// encryptAll encrypts plaintext under each of the keys concurrently, as a new version written by
+// writer.
+func encryptAll(ctx context.Context, keys []store.Key, name string, plaintext []byte, writer string) (store.ValueList, error) {
+	results := make(chan encryptResult, len(keys))
+	var wg sync.WaitGroup
+	for _, keyConfig := range keys {
+		wg.Add(1)
+		go func(keyConfig store.Key) {
+			defer wg.Done()
+			value, err := client.EncryptValue(ctx, keyConfig, name, plaintext)
+			results <- encryptResult{value, err}
+		}(keyConfig)
 	}
-	value.Algorithm = keyConfig.Algorithm
+	wg.Wait()
+	close(results)
 
-	var envelopeKey keymanager.EnvelopeKey
-	if algo.NeedsKey() {
-		keyManager, err := keymanager.New(keyConfig.KeyManager)
-		if err != nil {
-			return value, err
-		}
-		value.KeyManager = keyManager.Label()
-		envelopeKey, err = keyManager.GenerateEnvelopeKey(ctx, keyConfig.KeyID, name)
-		if err != nil {
-			return value, err
+	var valueList store.ValueList
+	for value := range results {
+		if value.err != nil {
+			return nil, value.err
 		}
-		value.KeyID = envelopeKey.ResolvedID
-		value.KeyCiphertext = base64.StdEncoding.EncodeToString(envelopeKey.Ciphertext)
+		valueList = append(valueList, value.value)
 	}
-
-	ciphertext, err := algo.Encrypt(envelopeKey.Plaintext, plaintext)
-	if err != nil {
-		return value, err
+	metadata := newMetadata(writer)
+	for i := range valueList {
+		valueList[i].Metadata = metadata
 	}
-	value.Ciphertext = base64.StdEncoding.EncodeToString(ciphertext)
-	return value, nil
+	return valueList, nil
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type rollback struct {
+	name     *string
+	version  *int
+	filename *string
+}
+
+// NewRollback configures the command to restore a previous version of a secret.
+func NewRollback(c *kingpin.CmdClause) shared.Command {
+	return &rollback{
+		name: shared.SecretNameArg(c),
+		version: c.Flag("to", "The version to restore. See biscuit history for the available versions.").
+			PlaceHolder("N").
+			Required().
+			Int(),
+		filename: shared.FilenameFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *rollback) Run(ctx context.Context) error {
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
+	versioned, err := asVersioned(database)
+	if err != nil {
+		return err
+	}
+	current, err := database.Get(*r.name)
+	if err != nil {
+		return err
+	}
+	if current.Version() == *r.version {
+		return fmt.Errorf("Version %d is already the current version of %s.", *r.version, *r.name)
+	}
+	previous, err := versioned.GetVersion(*r.name, *r.version)
+	if err != nil {
+		return err
+	}
+
+	// The restored values become a new version, so the rollback itself can be undone. The
+	// ciphertexts are copied as they are, so no keys are needed.
+	// The restored plaintext is as old as the version it comes from, and expires when it did.
+	var keys []store.Key
+	for _, value := range previous {
+		keys = append(keys, value.Key)
+	}
+	metadata := newMetadata(author(ctx, keys))
+	metadata.Changed = previous.Metadata().RotatedAt()
+	restored := make(store.ValueList, len(previous))
+	for i, value := range previous {
+		value.Metadata = metadata
+		restored[i] = value
+	}
+	restored = store.InheritMetadata(restored, current, store.Metadata{Expires: previous.Metadata().Expires})
+	if err := database.CompareAndSwap(*r.name, current, restored); err != nil {
+		return err
+	}
+	fmt.Printf("Restored version %d of %s.\n", *r.version, *r.name)
+	return nil
+}
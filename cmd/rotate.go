@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	myAWS "github.com/dcoker/biscuit/internal/aws"
+	"github.com/dcoker/biscuit/internal/aws/arn"
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type rotate struct {
+	names          *[]string
+	all            *bool
+	dryRun         *bool
+	filename       *string
+	regionPriority *[]string
+}
+
+// NewRotate configures the command to re-encrypt secrets under the current template.
+func NewRotate(c *kingpin.CmdClause) shared.Command {
+	return &rotate{
+		names: c.Flag("name", "Only rotate the named secret. May be repeated.").
+			Short('n').
+			PlaceHolder("NAME").
+			Strings(),
+		all: c.Flag("all", "Re-encrypt every selected secret, including those that already match "+
+			"the template.").Bool(),
+		dryRun: c.Flag("dry-run", "List the secrets that would be re-encrypted and why, but do not "+
+			"change anything.").Bool(),
+		filename:       shared.FilenameFlag(c),
+		regionPriority: shared.AwsRegionPriorityFlag(c),
+	}
+}
+
+// Run runs the command.
+func (r *rotate) Run(ctx context.Context) error {
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
+	keys, err := database.GetKeyIds()
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.names)
+	if err != nil {
+		return err
+	}
+	resolved, err := resolveKeys(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	updates := make(store.EntryMap)
+	var writer string
+	for _, name := range names {
+		values := entries[name]
+		reasons := values.Differences(resolved)
+		if len(reasons) == 0 {
+			if !*r.all {
+				continue
+			}
+			reasons = []string{"--all was specified"}
+		}
+		if *r.dryRun {
+			fmt.Printf("%s: would re-encrypt: %s\n", name, strings.Join(reasons, "; "))
+			continue
+		}
+		plaintext, err := decryptFirst(ctx, values, name, *r.regionPriority)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if writer == "" {
+			writer = author(ctx, keys)
+		}
+		reencrypted, err := encryptAll(ctx, keys, name, plaintext, writer)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		// The plaintext is unchanged, so the secret is no nearer to being rotated.
+		updates[name] = reencrypted.Reencrypted()
+		fmt.Printf("%s: re-encrypted: %s\n", name, strings.Join(reasons, "; "))
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	err = database.Update(func(current store.EntryMap) error {
+		// Don't overwrite a secret that was changed since we decrypted it.
+		for name, values := range updates {
+			if !current[name].Equal(entries[name]) {
+				return fmt.Errorf("%s: %w", name, store.ErrConflict)
+			}
+			current[name] = store.InheritMetadata(values, current[name], store.Metadata{})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// The previous versions are still encrypted under the old keys, which may be about to be retired.
+	if versioned, ok := database.(store.Versioned); ok {
+		var names []string
+		for name := range updates {
+			names = append(names, name)
+		}
+		if err := versioned.DropHistory(names...); err != nil {
+			return fmt.Errorf("the previous versions could not be removed: %w", err)
+		}
+	}
+	return nil
+}
+
+// resolveKeys returns keys with each KMS alias replaced by the ARN of the key that it points to, which
+// is the key ID that values encrypted under the alias record.
+func resolveKeys(ctx context.Context, keys []store.Key) ([]store.Key, error) {
+	resolved := make([]store.Key, len(keys))
+	for i, key := range keys {
+		resolved[i] = key
+		if !keymanager.IsKms(key.KeyManager) {
+			continue
+		}
+		parsed, err := arn.New(key.KeyID)
+		if err == nil && parsed.IsKmsKey() {
+			continue
+		}
+		cfg, err := myAWS.NewConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if parsed.Region != "" {
+			cfg.Region = parsed.Region
+		}
+		output, err := kms.NewFromConfig(cfg).DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(key.KeyID)})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key.KeyID, err)
+		}
+		resolved[i].KeyID = aws.ToString(output.KeyMetadata.Arn)
+	}
+	return resolved, nil
+}
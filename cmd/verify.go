@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/dcoker/biscuit/client"
+	"github.com/dcoker/biscuit/cmd/internal/shared"
+	stringsFunc "github.com/dcoker/biscuit/internal/strings"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+type verify struct {
+	names    *[]string
+	filename *string
+}
+
+// NewVerify configures the command to check that every value of every secret can be decrypted.
+func NewVerify(c *kingpin.CmdClause) shared.Command {
+	return &verify{
+		names:    c.Arg("name", "Names of the secrets to verify. If not set, all secrets are verified.").Strings(),
+		filename: shared.FilenameFlag(c),
+	}
+}
+
+// keyResults counts the values that verified and failed under one key.
+type keyResults struct {
+	ok, failed int
+}
+
+// Run runs the command. Unlike get, it decrypts every value of each secret rather than stopping at
+// the first that succeeds, so that a copy that can no longer be decrypted is noticed while the
+// others still can be.
+func (r *verify) Run(ctx context.Context) error {
+	database, err := store.Open(ctx, *r.filename)
+	if err != nil {
+		return err
+	}
+	entries, err := database.GetAll()
+	if err != nil {
+		return err
+	}
+	names, err := selectNames(entries, *r.names)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]*keyResults)
+	var total, failed int
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKEY\tRESULT")
+	for _, name := range names {
+		values := entries[name]
+		if len(values) == 0 {
+			fmt.Fprintf(w, "%s\t-\tfailed: the secret has no values\n", name)
+			total++
+			failed++
+			continue
+		}
+		for i, result := range verifyValues(ctx, name, values) {
+			key := describeKey(values[i])
+			if byKey[key] == nil {
+				byKey[key] = &keyResults{}
+			}
+			total++
+			if result == "ok" {
+				byKey[key].ok++
+			} else {
+				byKey[key].failed++
+				failed++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, key, result)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var keys []string
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tOK\tFAILED")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", key, byKey[key].ok, byKey[key].failed)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d %s failed to verify.", failed, total,
+			stringsFunc.Pluralize("value", total))
+	}
+	return nil
+}
+
+// verifyValues decrypts each of values and returns, for each, "ok" or why it failed. Every value
+// must decrypt to the same plaintext; if they disagree, the values that differ from the plaintext
+// most of them share are reported as mismatched.
+func verifyValues(ctx context.Context, name string, values store.ValueList) []string {
+	results := make([]string, len(values))
+	plaintexts := make([][]byte, len(values))
+	for i, value := range values {
+		plaintext, err := client.DecryptValue(ctx, value, name)
+		if err != nil {
+			results[i] = "failed: " + err.Error()
+			continue
+		}
+		plaintexts[i] = plaintext
+		results[i] = "ok"
+	}
+
+	// Find the plaintext that the most values decrypted to.
+	var consensus []byte
+	best := 0
+	for i := range values {
+		if results[i] != "ok" {
+			continue
+		}
+		agree := 0
+		for j := range values {
+			if results[j] == "ok" && bytes.Equal(plaintexts[i], plaintexts[j]) {
+				agree++
+			}
+		}
+		if agree > best {
+			consensus, best = plaintexts[i], agree
+		}
+	}
+	for i := range values {
+		if results[i] == "ok" && !bytes.Equal(plaintexts[i], consensus) {
+			results[i] = "mismatch: decrypts to a different plaintext than the other values"
+		}
+	}
+	return results
+}
+
+// describeKey names the key a value is encrypted under, or its algorithm if it needs no key.
+func describeKey(value store.Value) string {
+	if value.KeyManager == "" {
+		return value.Algorithm
+	}
+	return value.KeyManager + " " + value.KeyID
+}
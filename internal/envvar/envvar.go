@@ -0,0 +1,24 @@
+package envvar
+
+import (
+	"strings"
+)
+
+// Name converts the name of a secret into the name of an environment variable. Letters are
+// uppercased, and any character other than a letter, digit or underscore becomes an underscore. The
+// result is prefixed with an underscore if it would otherwise start with a digit.
+func Name(prefix, secretName string) string {
+	mapped := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		}
+		return '_'
+	}, prefix+secretName)
+	if len(mapped) > 0 && mapped[0] >= '0' && mapped[0] <= '9' {
+		mapped = "_" + mapped
+	}
+	return mapped
+}
@@ -0,0 +1,18 @@
+package envvar_test
+
+import (
+	"testing"
+
+	"github.com/dcoker/biscuit/internal/envvar"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestName(t *testing.T) {
+	assert.Equal(t, "LAUNCH_CODES", envvar.Name("", "launch_codes"))
+	assert.Equal(t, "APP_LAUNCH_CODES", envvar.Name("APP_", "launch_codes"))
+	assert.Equal(t, "APP_LAUNCH_CODES", envvar.Name("app_", "launch-codes"))
+	assert.Equal(t, "DB_PASSWORD_PRIMARY", envvar.Name("", "db.password/primary"))
+	assert.Equal(t, "_1PASSWORD", envvar.Name("", "1password"))
+	assert.Equal(t, "X_1PASSWORD", envvar.Name("X_", "1password"))
+	assert.Equal(t, "CAF_", envvar.Name("", "café"))
+}
@@ -0,0 +1,233 @@
+// Package format writes decrypted secrets in formats understood by other tools.
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/dcoker/biscuit/internal/envvar"
+	"gopkg.in/yaml.v2"
+)
+
+// Names of the supported formats.
+const (
+	YAML       = "yaml"
+	JSON       = "json"
+	Dotenv     = "dotenv"
+	Shell      = "shell"
+	Properties = "properties"
+)
+
+// Names returns the names of the supported formats.
+func Names() []string {
+	return []string{YAML, JSON, Dotenv, Shell, Properties}
+}
+
+// Write writes secrets to w in the requested format, ordered by name. The dotenv and shell formats
+// use envvar.Name to turn secret names into variable names.
+func Write(w io.Writer, format string, secrets map[string][]byte) error {
+	var output string
+	var err error
+	switch format {
+	case YAML:
+		output, err = toYAML(secrets)
+	case JSON:
+		output, err = toJSON(secrets)
+	case Dotenv:
+		output, err = toVariables(secrets, dotenvLine)
+	case Shell:
+		output, err = toVariables(secrets, shellLine)
+	case Properties:
+		output, err = toProperties(secrets)
+	default:
+		err = fmt.Errorf("unsupported format '%s'", format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, output)
+	return err
+}
+
+func sortedNames(secrets map[string][]byte) []string {
+	var names []string
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toYAML relies on the YAML encoder, which quotes multi-line values and emits values that are not
+// valid UTF-8 as !!binary.
+func toYAML(secrets map[string][]byte) (string, error) {
+	if len(secrets) == 0 {
+		return "", nil
+	}
+	values := make(map[string]string)
+	for name, value := range secrets {
+		values[name] = string(value)
+	}
+	output, err := yaml.Marshal(values)
+	return string(output), err
+}
+
+func toJSON(secrets map[string][]byte) (string, error) {
+	values := make(map[string]string)
+	for name, value := range secrets {
+		if !utf8.Valid(value) {
+			return "", fmt.Errorf("%s: JSON cannot represent values that are not valid UTF-8; "+
+				"use the yaml or properties format", name)
+		}
+		values[name] = string(value)
+	}
+	output, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(output) + "\n", nil
+}
+
+func toVariables(secrets map[string][]byte, line func(variable string, value []byte) (string, error)) (string, error) {
+	var b strings.Builder
+	secretForVariable := make(map[string]string)
+	for _, name := range sortedNames(secrets) {
+		variable := envvar.Name("", name)
+		if other, present := secretForVariable[variable]; present {
+			return "", fmt.Errorf("secrets %s and %s would both be written as %s", other, name, variable)
+		}
+		secretForVariable[variable] = name
+		output, err := line(variable, secrets[name])
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		b.WriteString(output)
+	}
+	return b.String(), nil
+}
+
+// dotenvLine writes a double-quoted value. Backslash escapes are used for newlines, carriage
+// returns, tabs, quotes, backslashes and dollar signs; other control characters cannot be
+// represented.
+func dotenvLine(variable string, value []byte) (string, error) {
+	if !utf8.Valid(value) {
+		return "", fmt.Errorf("dotenv cannot represent values that are not valid UTF-8")
+	}
+	var b strings.Builder
+	b.WriteString(variable + "=\"")
+	for _, r := range string(value) {
+		switch r {
+		case '\\', '"', '$':
+			b.WriteRune('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				return "", fmt.Errorf("dotenv cannot represent control character %U", r)
+			}
+			b.WriteRune(r)
+		}
+	}
+	b.WriteString("\"\n")
+	return b.String(), nil
+}
+
+// shellLine writes a line that can be evaluated by bash, ksh or zsh. Printable values, including
+// multi-line values, are single-quoted. Anything else uses ANSI-C quoting ($'...'), which can
+// represent arbitrary bytes other than NUL.
+func shellLine(variable string, value []byte) (string, error) {
+	if isPrintable(value) {
+		return "export " + variable + "='" + strings.ReplaceAll(string(value), "'", `'\''`) + "'\n", nil
+	}
+	var b strings.Builder
+	b.WriteString("export " + variable + "=$'")
+	for _, c := range value {
+		switch {
+		case c == 0:
+			return "", fmt.Errorf("shell variables cannot contain NUL bytes")
+		case c == '\\' || c == '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString("'\n")
+	return b.String(), nil
+}
+
+func isPrintable(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+	for _, r := range string(value) {
+		if (r < 0x20 && r != '\n' && r != '\t') || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// toProperties writes a Java .properties file. Properties files are ISO-8859-1, so every character
+// outside of printable ASCII is written as a \uXXXX escape. Bytes that are not valid UTF-8 are
+// written as the ISO-8859-1 character with the same value.
+func toProperties(secrets map[string][]byte) (string, error) {
+	var b strings.Builder
+	for _, name := range sortedNames(secrets) {
+		b.WriteString(escapeProperty([]byte(name), true))
+		b.WriteByte('=')
+		b.WriteString(escapeProperty(secrets[name], false))
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+func escapeProperty(s []byte, isKey bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRune(s[i:])
+		if r == utf8.RuneError && size <= 1 {
+			r = rune(s[i])
+		}
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == ' ' && (isKey || i == 0):
+			b.WriteString(`\ `)
+		case r < 0x20 || r > 0x7e:
+			if r1, r2 := utf16.EncodeRune(r); r1 != unicode.ReplacementChar {
+				fmt.Fprintf(&b, `\u%04x\u%04x`, r1, r2)
+			} else {
+				fmt.Fprintf(&b, `\u%04x`, r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String()
+}
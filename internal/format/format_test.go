@@ -0,0 +1,80 @@
+package format_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/dcoker/biscuit/internal/format"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+var secrets = map[string][]byte{
+	"password":  []byte("god"),
+	"multiline": []byte("line 1\nline 2\n"),
+	"quotes":    []byte(`it's "$HOME" \o/`),
+}
+
+func write(t *testing.T, name string, secrets map[string][]byte) string {
+	var b bytes.Buffer
+	assert.NoError(t, format.Write(&b, name, secrets))
+	return b.String()
+}
+
+func TestWrite_YAML(t *testing.T) {
+	withBinary := map[string][]byte{"binary": {0xff, 0x00, 'x'}}
+	for k, v := range secrets {
+		withBinary[k] = v
+	}
+	var decoded map[string]string
+	assert.NoError(t, yaml.Unmarshal([]byte(write(t, format.YAML, withBinary)), &decoded))
+	assert.Len(t, decoded, len(withBinary))
+	for name, value := range withBinary {
+		assert.Equal(t, string(value), decoded[name])
+	}
+	assert.Equal(t, "", write(t, format.YAML, nil))
+}
+
+func TestWrite_JSON(t *testing.T) {
+	var decoded map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(write(t, format.JSON, secrets)), &decoded))
+	for name, value := range secrets {
+		assert.Equal(t, string(value), decoded[name])
+	}
+	assert.Error(t, format.Write(&bytes.Buffer{}, format.JSON, map[string][]byte{"b": {0xff}}))
+}
+
+func TestWrite_Dotenv(t *testing.T) {
+	assert.Equal(t, `MULTILINE="line 1\nline 2\n"
+PASSWORD="god"
+QUOTES="it's \"\$HOME\" \\o/"
+`, write(t, format.Dotenv, secrets))
+	assert.Error(t, format.Write(&bytes.Buffer{}, format.Dotenv, map[string][]byte{"b": {0x01}}))
+	assert.Error(t, format.Write(&bytes.Buffer{}, format.Dotenv, map[string][]byte{"a-b": nil, "a.b": nil}))
+}
+
+func TestWrite_Shell(t *testing.T) {
+	assert.Equal(t, `export MULTILINE='line 1
+line 2
+'
+export PASSWORD='god'
+export QUOTES='it'\''s "$HOME" \o/'
+`, write(t, format.Shell, secrets))
+	assert.Equal(t, `export BINARY=$'\xff\x01it\'s'`+"\n",
+		write(t, format.Shell, map[string][]byte{"binary": []byte("\xff\x01it's")}))
+	assert.Error(t, format.Write(&bytes.Buffer{}, format.Shell, map[string][]byte{"b": {0x00}}))
+}
+
+func TestWrite_Properties(t *testing.T) {
+	assert.Equal(t, `multiline=line 1\nline 2\n
+password=god
+quotes=it's "$HOME" \\o/
+`, write(t, format.Properties, secrets))
+	assert.Equal(t, `a\ b\:c=\ x\=y\u00e9\ud83d\ude00\u00ff`+"\n",
+		write(t, format.Properties, map[string][]byte{"a b:c": []byte(" x=yé😀\xff")}))
+}
+
+func TestWrite_Unsupported(t *testing.T) {
+	assert.Error(t, format.Write(&bytes.Buffer{}, "xml", secrets))
+}
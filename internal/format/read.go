@@ -0,0 +1,174 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReadableNames returns the names of the formats supported by Read.
+func ReadableNames() []string {
+	return []string{Dotenv, JSON, YAML}
+}
+
+// Read parses name/value pairs from r. JSON and YAML sources must be a single mapping of names to
+// scalar values.
+func Read(r io.Reader, format string) (map[string][]byte, error) {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case Dotenv:
+		return fromDotenv(contents)
+	case JSON:
+		return fromJSON(contents)
+	case YAML:
+		return fromYAML(contents)
+	}
+	return nil, fmt.Errorf("unsupported format '%s'", format)
+}
+
+// fromJSON keeps numbers exactly as they are written in the source, so that values such as account
+// IDs are not reformatted or rounded.
+func fromJSON(contents []byte) (map[string][]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the JSON object")
+	}
+	secrets := make(map[string][]byte)
+	for name, value := range values {
+		switch v := value.(type) {
+		case string:
+			secrets[name] = []byte(v)
+		case json.Number:
+			secrets[name] = []byte(v.String())
+		case bool:
+			secrets[name] = []byte(fmt.Sprint(v))
+		default:
+			return nil, fmt.Errorf("%s: value must be a string, number or boolean", name)
+		}
+	}
+	return secrets, nil
+}
+
+func fromYAML(contents []byte) (map[string][]byte, error) {
+	var values map[string]string
+	if err := yaml.Unmarshal(contents, &values); err != nil {
+		return nil, err
+	}
+	secrets := make(map[string][]byte)
+	for name, value := range values {
+		secrets[name] = []byte(value)
+	}
+	return secrets, nil
+}
+
+// fromDotenv parses NAME=VALUE lines, optionally preceded by "export". Blank lines and lines
+// starting with # are ignored. Double-quoted values may span lines and understand the escapes
+// written by the dotenv output format; single-quoted values are taken literally; unquoted values
+// are trimmed and end at " #".
+func fromDotenv(contents []byte) (map[string][]byte, error) {
+	secrets := make(map[string][]byte)
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(nil, len(contents)+1)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		equals := strings.IndexByte(line, '=')
+		if equals < 1 {
+			return nil, fmt.Errorf("line %d: expected NAME=VALUE", lineNumber)
+		}
+		name := strings.TrimSpace(line[:equals])
+		rest := strings.TrimSpace(line[equals+1:])
+
+		var value string
+		switch {
+		case strings.HasPrefix(rest, `"`):
+			raw := rest[1:]
+			start := lineNumber
+			end := closingQuote(raw)
+			for end < 0 {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated double-quoted value", start)
+				}
+				lineNumber++
+				raw += "\n" + scanner.Text()
+				end = closingQuote(raw)
+			}
+			var err error
+			if value, err = unescapeDotenv(raw[:end]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", start, err)
+			}
+		case strings.HasPrefix(rest, `'`):
+			end := strings.LastIndexByte(rest, '\'')
+			if end < 1 {
+				return nil, fmt.Errorf("line %d: unterminated single-quoted value", lineNumber)
+			}
+			value = rest[1:end]
+		default:
+			if comment := strings.Index(rest, " #"); comment >= 0 {
+				rest = rest[:comment]
+			}
+			value = strings.TrimSpace(rest)
+		}
+		secrets[name] = []byte(value)
+	}
+	return secrets, scanner.Err()
+}
+
+// closingQuote returns the index of the first unescaped double quote in s, or -1.
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeDotenv(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '\\', '"', '$', '\'':
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,83 @@
+package format_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dcoker/biscuit/internal/format"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRead_Dotenv(t *testing.T) {
+	input := `# comment
+PASSWORD=god
+export DB_USER = oreilly # inline comment
+
+SINGLE='it is "$literal" \n'
+DOUBLE="line 1\nit's \"quoted\" \$HOME \\o/" # "comment"
+SPANNING="first
+second"
+EMPTY=
+`
+	secrets, err := format.Read(strings.NewReader(input), format.Dotenv)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"PASSWORD": []byte("god"),
+		"DB_USER":  []byte("oreilly"),
+		"SINGLE":   []byte(`it is "$literal" \n`),
+		"DOUBLE":   []byte("line 1\nit's \"quoted\" $HOME \\o/"),
+		"SPANNING": []byte("first\nsecond"),
+		"EMPTY":    []byte(""),
+	}, secrets)
+
+	for _, bad := range []string{"NOEQUALS", "=value", `A="unterminated`, `A='unterminated`} {
+		_, err := format.Read(strings.NewReader(bad), format.Dotenv)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRead_DotenvRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, format.Write(&b, format.Dotenv, secrets))
+	read, err := format.Read(&b, format.Dotenv)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"PASSWORD":  secrets["password"],
+		"MULTILINE": secrets["multiline"],
+		"QUOTES":    secrets["quotes"],
+	}, read)
+}
+
+func TestRead_JSONAndYAML(t *testing.T) {
+	for _, f := range []string{format.JSON, format.YAML} {
+		var b bytes.Buffer
+		assert.NoError(t, format.Write(&b, f, secrets))
+		read, err := format.Read(&b, f)
+		assert.NoError(t, err)
+		assert.Equal(t, secrets, read, f)
+	}
+
+	read, err := format.Read(strings.NewReader(`{"port": 8080, "debug": true}`), format.JSON)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"port": []byte("8080"), "debug": []byte("true")}, read)
+	_, err = format.Read(strings.NewReader(`{"nested": {"a": "b"}}`), format.JSON)
+	assert.Error(t, err)
+	_, err = format.Read(strings.NewReader(`{"a": "b"} {"c": "d"}`), format.JSON)
+	assert.Error(t, err)
+	_, err = format.Read(strings.NewReader(`- a list`), format.YAML)
+	assert.Error(t, err)
+}
+
+func TestRead_JSONNumbers(t *testing.T) {
+	input := `{"id": 123456789012, "big": 9007199254740993, "signed": -12, "exponent": 1E+3, "pi": 3.10}`
+	read, err := format.Read(strings.NewReader(input), format.JSON)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"id":       []byte("123456789012"),
+		"big":      []byte("9007199254740993"),
+		"signed":   []byte("-12"),
+		"exponent": []byte("1E+3"),
+		"pi":       []byte("3.10"),
+	}, read)
+}
@@ -0,0 +1,41 @@
+// Package period parses lengths of time such as "90d" that are too long to write conveniently as a
+// time.Duration.
+package period
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var units = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// Parse parses a whole number of days ("90d") or weeks ("12w"), or anything time.ParseDuration
+// accepts ("36h"). The result must be positive.
+func Parse(s string) (time.Duration, error) {
+	duration, err := parse(s)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid period %q: expected a number of days such as 90d.", s)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("Invalid period %q: must be greater than zero.", s)
+	}
+	return duration, nil
+}
+
+func parse(s string) (time.Duration, error) {
+	for suffix, unit := range units {
+		if strings.HasSuffix(s, suffix) {
+			count, err := strconv.Atoi(strings.TrimSuffix(s, suffix))
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
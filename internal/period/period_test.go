@@ -0,0 +1,30 @@
+package period_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcoker/biscuit/internal/period"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	for input, expected := range map[string]time.Duration{
+		"90d": 90 * 24 * time.Hour,
+		"1d":  24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"36h": 36 * time.Hour,
+		"90m": 90 * time.Minute,
+	} {
+		actual, err := period.Parse(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+}
+
+func TestParse_invalid(t *testing.T) {
+	for _, input := range []string{"", "d", "90", "ninety days", "1.5d", "0d", "-3d", "0s"} {
+		_, err := period.Parse(input)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,103 @@
+// Package shamir implements Shamir's secret sharing over GF(2^8), splitting a secret into shares
+// such that any threshold of them recover it and fewer reveal nothing about it.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// Split divides secret into n shares, any threshold of which can recover it. Each share is one byte
+// longer than the secret: the first byte is its x coordinate, which is never zero.
+func Split(secret []byte, n, threshold int) ([][]byte, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("need 2 <= threshold <= shares <= 255, got %d of %d", threshold, n)
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("cannot split an empty secret")
+	}
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+	// Each byte of the secret is the constant term of its own random polynomial of degree
+	// threshold-1, which is evaluated at each share's x coordinate.
+	coefficients := make([]byte, threshold)
+	for j, b := range secret {
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		coefficients[0] = b
+		for _, share := range shares {
+			share[j+1] = evaluate(coefficients, share[0])
+		}
+	}
+	return shares, nil
+}
+
+// Combine recovers the secret from at least threshold shares produced by Split. Too few shares
+// give a wrong answer rather than an error, so callers must know the threshold.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("need at least two shares")
+	}
+	length := len(shares[0])
+	seen := make(map[byte]bool)
+	for _, share := range shares {
+		if len(share) != length || length < 2 {
+			return nil, errors.New("shares have different lengths")
+		}
+		if share[0] == 0 || seen[share[0]] {
+			return nil, errors.New("shares have invalid or duplicate x coordinates")
+		}
+		seen[share[0]] = true
+	}
+	// Lagrange interpolation at x = 0.
+	secret := make([]byte, length-1)
+	for i, share := range shares {
+		var basis byte = 1
+		for k, other := range shares {
+			if k != i {
+				basis = mul(basis, div(other[0], share[0]^other[0]))
+			}
+		}
+		for j := range secret {
+			secret[j] ^= mul(basis, share[j+1])
+		}
+	}
+	return secret, nil
+}
+
+// evaluate returns the value of the polynomial with the given coefficients, lowest degree first, at
+// x.
+func evaluate(coefficients []byte, x byte) byte {
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// mul multiplies in GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1. It takes the same time
+// for all inputs.
+func mul(a, b byte) byte {
+	var product byte
+	for i := 0; i < 8; i++ {
+		product ^= -(b & 1) & a
+		a = (a << 1) ^ (-(a >> 7) & 0x1b)
+		b >>= 1
+	}
+	return product
+}
+
+// div divides in GF(2^8). b must not be zero.
+func div(a, b byte) byte {
+	// b^254 is the inverse of b.
+	inverse := b
+	for i := 0; i < 6; i++ {
+		inverse = mul(mul(inverse, inverse), b)
+	}
+	return mul(a, mul(inverse, inverse))
+}
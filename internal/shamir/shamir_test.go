@@ -0,0 +1,58 @@
+package shamir_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dcoker/biscuit/internal/shamir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("correct horse battery staple....")
+	shares, err := shamir.Split(secret, 5, 3)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 5)
+	for _, share := range shares {
+		assert.Len(t, share, len(secret)+1)
+	}
+
+	// Any 3 of the 5 shares recover the secret.
+	for a := 0; a < 5; a++ {
+		for b := a + 1; b < 5; b++ {
+			for c := b + 1; c < 5; c++ {
+				recovered, err := shamir.Combine([][]byte{shares[c], shares[a], shares[b]})
+				assert.NoError(t, err)
+				assert.Equal(t, secret, recovered)
+			}
+		}
+	}
+	recovered, err := shamir.Combine(shares)
+	assert.NoError(t, err)
+	assert.Equal(t, secret, recovered)
+
+	// Two are not enough.
+	recovered, err = shamir.Combine(shares[:2])
+	assert.NoError(t, err)
+	assert.False(t, bytes.Equal(secret, recovered))
+}
+
+func TestSplit_invalid(t *testing.T) {
+	for _, c := range []struct{ n, threshold int }{{3, 1}, {2, 3}, {256, 2}} {
+		_, err := shamir.Split([]byte("secret"), c.n, c.threshold)
+		assert.Error(t, err, c)
+	}
+	_, err := shamir.Split(nil, 3, 2)
+	assert.Error(t, err)
+}
+
+func TestCombine_invalid(t *testing.T) {
+	shares, err := shamir.Split([]byte("secret"), 3, 2)
+	assert.NoError(t, err)
+	_, err = shamir.Combine(shares[:1])
+	assert.Error(t, err)
+	_, err = shamir.Combine([][]byte{shares[0], shares[0]})
+	assert.Error(t, err)
+	_, err = shamir.Combine([][]byte{shares[0], shares[1][:3]})
+	assert.Error(t, err)
+}
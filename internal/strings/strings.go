@@ -18,7 +18,7 @@ func FriendlyJoin(words []string) string {
 }
 
 func Pluralize(word string, count int) string {
-	if count > 1 {
+	if count != 1 {
 		return word + "s"
 	}
 	return word
@@ -14,3 +14,9 @@ func TestFriendlyJoin(t *testing.T) {
 	assert.Equal(t, "us-east-1, us-west-1 and us-west-2", strings.FriendlyJoin([]string{"us-west-2", "us-east-1",
 		"us-west-1"}))
 }
+
+func TestPluralize(t *testing.T) {
+	assert.Equal(t, "keys", strings.Pluralize("key", 0))
+	assert.Equal(t, "key", strings.Pluralize("key", 1))
+	assert.Equal(t, "keys", strings.Pluralize("key", 2))
+}
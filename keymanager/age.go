@@ -0,0 +1,144 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+)
+
+const (
+	// AgeLabel is the label for the age key manager.
+	AgeLabel = "age"
+	// AgeIdentityEnv is the environment variable that holds an age identity (AGE-SECRET-KEY-1...)
+	// or the path of a file of identities. If it is not set, identities are read from
+	// AgeIdentityFile in the user's configuration directory.
+	AgeIdentityEnv = "BISCUIT_AGE_IDENTITY"
+	// AgeIdentityFile is the file, relative to the user's configuration directory, that identities
+	// are read from if AgeIdentityEnv is not set.
+	AgeIdentityFile = "biscuit/age-identities.txt"
+)
+
+func init() {
+	registry[AgeLabel] = NewAge
+}
+
+var (
+	errAgeWrongSecret = errors.New("key_ciphertext was encrypted for a different secret")
+
+	// Identities are read once per process.
+	ageIdentitiesMu sync.Mutex
+	ageIdentities   []age.Identity
+)
+
+// Age is a KeyManager that encrypts each data key to an age X25519 recipient, so that it can be
+// decrypted offline by whoever holds the matching identity. The key ID is the recipient, such as
+// age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p.
+type Age struct{}
+
+// NewAge returns a new Age.
+func NewAge() KeyManager {
+	return &Age{}
+}
+
+// GenerateEnvelopeKey generates a random data key and encrypts it to the recipient keyID. The name
+// of the secret is encrypted along with the key, and checked by Decrypt.
+func (a *Age) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	recipient, err := age.ParseX25519Recipient(keyID)
+	if err != nil {
+		return EnvelopeKey{}, fmt.Errorf("%s: %s", keyID, err)
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return EnvelopeKey{}, err
+	}
+	var ciphertext bytes.Buffer
+	w, err := age.Encrypt(&ciphertext, recipient)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	if _, err := w.Write(append(append([]byte(nil), dataKey...), secretID...)); err != nil {
+		return EnvelopeKey{}, err
+	}
+	if err := w.Close(); err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: recipient.String(),
+		Plaintext:  dataKey,
+		Ciphertext: ciphertext.Bytes()}, nil
+}
+
+// Decrypt decrypts the data key with any of the identities from AgeIdentityEnv or AgeIdentityFile.
+func (a *Age) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	identities, err := readAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+	r, err := age.Decrypt(bytes.NewReader(keyCiphertext), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", keyID, err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) < 32 || string(plaintext[32:]) != secretID {
+		return nil, errAgeWrongSecret
+	}
+	return plaintext[:32], nil
+}
+
+// Label returns AgeLabel.
+func (a *Age) Label() string {
+	return AgeLabel
+}
+
+// readAgeIdentities returns the identities in AgeIdentityEnv, or in the file it names, or in
+// AgeIdentityFile.
+func readAgeIdentities() ([]age.Identity, error) {
+	ageIdentitiesMu.Lock()
+	defer ageIdentitiesMu.Unlock()
+	if ageIdentities != nil {
+		return ageIdentities, nil
+	}
+
+	value := os.Getenv(AgeIdentityEnv)
+	var source io.Reader
+	if strings.HasPrefix(value, "AGE-SECRET-KEY-") {
+		source = strings.NewReader(value)
+	} else {
+		path := value
+		if path == "" {
+			dir, err := os.UserConfigDir()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(dir, AgeIdentityFile)
+		}
+		f, err := os.Open(path)
+		if os.IsNotExist(err) && value == "" {
+			return nil, fmt.Errorf("Please set %s to an age identity or the path of an identity file, "+
+				"or put your identities in %s.", AgeIdentityEnv, path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		source = f
+	}
+	identities, err := age.ParseIdentities(source)
+	if err != nil {
+		return nil, fmt.Errorf("Reading age identities: %s", err)
+	}
+	ageIdentities = identities
+	return ageIdentities, nil
+}
@@ -0,0 +1,69 @@
+package keymanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+)
+
+// useAgeIdentity sets the identity that the age key manager reads, and forgets the ones it read
+// before.
+func useAgeIdentity(t *testing.T, value string) {
+	previous, set := os.LookupEnv(AgeIdentityEnv)
+	assert.NoError(t, os.Setenv(AgeIdentityEnv, value))
+	ageIdentities = nil
+	t.Cleanup(func() {
+		ageIdentities = nil
+		if set {
+			os.Setenv(AgeIdentityEnv, previous)
+		} else {
+			os.Unsetenv(AgeIdentityEnv)
+		}
+	})
+}
+
+func TestAge(t *testing.T) {
+	ctx := context.Background()
+	identity, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	other, err := age.GenerateX25519Identity()
+	assert.NoError(t, err)
+	recipient := identity.Recipient().String()
+
+	manager, err := New(AgeLabel)
+	assert.NoError(t, err)
+	key, err := manager.GenerateEnvelopeKey(ctx, recipient, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, recipient, key.ResolvedID)
+	assert.Len(t, key.Plaintext, 32)
+
+	useAgeIdentity(t, identity.String())
+	plaintext, err := manager.Decrypt(ctx, recipient, key.Ciphertext, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, key.Plaintext, plaintext)
+
+	// The key is bound to the name of the secret.
+	_, err = manager.Decrypt(ctx, recipient, key.Ciphertext, "username")
+	assert.Equal(t, errAgeWrongSecret, err)
+
+	// Identities can be read from a file, which may hold several.
+	path := filepath.Join(t.TempDir(), "identities.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("# comment\n"+other.String()+"\n"+identity.String()+"\n"), 0600))
+	useAgeIdentity(t, path)
+	plaintext, err = manager.Decrypt(ctx, recipient, key.Ciphertext, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, key.Plaintext, plaintext)
+
+	useAgeIdentity(t, other.String())
+	_, err = manager.Decrypt(ctx, recipient, key.Ciphertext, "password")
+	assert.Error(t, err)
+}
+
+func TestAge_invalidRecipient(t *testing.T) {
+	_, err := NewAge().GenerateEnvelopeKey(context.Background(), "age1nope", "password")
+	assert.Error(t, err)
+}
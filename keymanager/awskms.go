@@ -2,6 +2,9 @@ package keymanager
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
@@ -49,22 +52,25 @@ func (k *Kms) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID st
 		Ciphertext: generateDataKeyOutput.CiphertextBlob}, nil
 }
 
-// Decrypt decrypts the encrypted key.
+// Decrypt decrypts the encrypted key. If keyID is a multi-Region key, Decrypt uses its replicas in
+// the regions of WithRegionPriority first.
 func (k *Kms) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
-	client, err := newKmsClient(ctx, keyID)
-	if err != nil {
-		return nil, err
-	}
-	do, err := client.Decrypt(ctx, &kms.DecryptInput{
-		EncryptionContext: map[string]string{
-			"SecretName": secretID,
-		},
-		CiphertextBlob: keyCiphertext,
+	return decryptInRegions(ctx, keyID, func(regionalKeyID string) ([]byte, error) {
+		client, err := newKmsClient(ctx, regionalKeyID)
+		if err != nil {
+			return nil, err
+		}
+		do, err := client.Decrypt(ctx, &kms.DecryptInput{
+			EncryptionContext: map[string]string{
+				"SecretName": secretID,
+			},
+			CiphertextBlob: keyCiphertext,
+		})
+		if err != nil {
+			return []byte{}, err
+		}
+		return do.Plaintext, nil
 	})
-	if err != nil {
-		return []byte{}, err
-	}
-	return do.Plaintext, nil
 }
 
 // Label returns kmsLabel
@@ -72,6 +78,57 @@ func (k *Kms) Label() string {
 	return KmsLabel
 }
 
+// WithRegionPriority returns a context in which the KMS key managers decrypt under multi-Region
+// keys using the replicas in regions, in order, before the region that the key ID names.
+func WithRegionPriority(ctx context.Context, regions []string) context.Context {
+	return context.WithValue(ctx, regionPriorityKey{}, regions)
+}
+
+type regionPriorityKey struct{}
+
+// regionalKeyIDs returns the key IDs to decrypt under keyID with, in order. For a multi-Region key,
+// these are the replicas in the regions of WithRegionPriority followed by keyID itself. Replicas of
+// a multi-Region key share its key ID, so their ARNs differ only in the region.
+func regionalKeyIDs(ctx context.Context, keyID string) []string {
+	parsed, err := arn.New(keyID)
+	if err != nil || !parsed.IsKmsKey() || !strings.HasPrefix(parsed.Resource, "mrk-") {
+		return []string{keyID}
+	}
+	regions, _ := ctx.Value(regionPriorityKey{}).([]string)
+	var keyIDs []string
+	for _, region := range regions {
+		if region == "" || region == parsed.Region {
+			continue
+		}
+		replica := parsed
+		replica.Region = region
+		keyIDs = append(keyIDs, replica.String())
+	}
+	return append(keyIDs, keyID)
+}
+
+// decryptInRegions calls decrypt with each of the regionalKeyIDs of keyID until one succeeds.
+func decryptInRegions(ctx context.Context, keyID string, decrypt func(string) ([]byte, error)) ([]byte, error) {
+	keyIDs := regionalKeyIDs(ctx, keyID)
+	var failures []string
+	for _, regionalKeyID := range keyIDs {
+		plaintext, err := decrypt(regionalKeyID)
+		if err == nil {
+			return plaintext, nil
+		}
+		if len(keyIDs) == 1 {
+			return nil, err
+		}
+		failures = append(failures, fmt.Sprintf("%s: %s", regionalKeyID, err))
+	}
+	return nil, errors.New(strings.Join(failures, "; "))
+}
+
+// IsKms reports whether label is that of a key manager whose key IDs are AWS KMS ARNs.
+func IsKms(label string) bool {
+	return label == KmsLabel || label == KmsRsaLabel
+}
+
 func newKmsClient(ctx context.Context, larn string) (*kms.Client, error) {
 	cfg := myAWS.MustNewConfig(ctx)
 	parsed, err := arn.New(larn)
@@ -0,0 +1,53 @@
+package keymanager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegionalKeyIDs(t *testing.T) {
+	mrk := "arn:aws:kms:us-east-1:111111111111:key/mrk-1234abcd12ab34cd56ef1234567890ab"
+	ctx := WithRegionPriority(context.Background(), []string{"us-west-2", "us-east-1", "eu-west-1"})
+	assert.Equal(t, []string{
+		"arn:aws:kms:us-west-2:111111111111:key/mrk-1234abcd12ab34cd56ef1234567890ab",
+		"arn:aws:kms:eu-west-1:111111111111:key/mrk-1234abcd12ab34cd56ef1234567890ab",
+		mrk,
+	}, regionalKeyIDs(ctx, mrk))
+
+	// Without a region priority, the key is used in its own region.
+	assert.Equal(t, []string{mrk}, regionalKeyIDs(context.Background(), mrk))
+
+	// Other keys exist in one region only.
+	for _, keyID := range []string{
+		"arn:aws:kms:us-east-1:111111111111:key/1234abcd-12ab-34cd-56ef-1234567890ab",
+		"arn:aws:kms:us-east-1:111111111111:alias/mrk-biscuit",
+		"mrk-1234abcd12ab34cd56ef1234567890ab",
+	} {
+		assert.Equal(t, []string{keyID}, regionalKeyIDs(ctx, keyID))
+	}
+}
+
+func TestDecryptInRegions(t *testing.T) {
+	mrk := "arn:aws:kms:us-east-1:111111111111:key/mrk-1234abcd12ab34cd56ef1234567890ab"
+	ctx := WithRegionPriority(context.Background(), []string{"us-west-2"})
+	var tried []string
+	plaintext, err := decryptInRegions(ctx, mrk, func(keyID string) ([]byte, error) {
+		tried = append(tried, keyID)
+		if keyID != mrk {
+			return nil, errors.New("unavailable")
+		}
+		return []byte("plaintext"), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("plaintext"), plaintext)
+	assert.Len(t, tried, 2)
+
+	_, err = decryptInRegions(ctx, mrk, func(keyID string) ([]byte, error) {
+		return nil, errors.New("unavailable")
+	})
+	assert.EqualError(t, err, "arn:aws:kms:us-west-2:111111111111:key/mrk-1234abcd12ab34cd56ef1234567890ab: "+
+		"unavailable; "+mrk+": unavailable")
+}
@@ -0,0 +1,213 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/dcoker/biscuit/internal/aws/arn"
+)
+
+const (
+	// KmsRsaLabel is the label for AWS KMS with asymmetric RSA keys.
+	KmsRsaLabel = "kms-rsa"
+	// KmsPublicKeyCacheDir is the directory, relative to the user's cache directory, that the public
+	// keys of KmsRsa keys are cached in.
+	KmsPublicKeyCacheDir = "biscuit/kms-public-keys"
+)
+
+func init() {
+	registry[KmsRsaLabel] = NewKmsRsa
+}
+
+var (
+	errKmsRsaWrongSecret = errors.New("key_ciphertext was encrypted for a different secret")
+
+	// Public keys are fetched from KMS once and cached, in memory and on disk, by key ARN. Aliases
+	// can be repointed, so they are resolved to key ARNs once per process and not cached on disk.
+	kmsPublicKeysMu sync.Mutex
+	kmsPublicKeys   = make(map[string]kmsPublicKey)
+	kmsKeyArns      = make(map[string]string)
+)
+
+// KmsRsa is a KeyManager for asymmetric RSA keys in AWS KMS, with a key usage of ENCRYPT_DECRYPT.
+// Data keys are encrypted locally with the key's public key, so that hosts that may only call
+// DescribeKey and GetPublicKey, and not Encrypt or Decrypt, can put secrets. After the first use of
+// a key, its public key is read from KmsPublicKeyCacheDir; only an alias is still resolved with
+// DescribeKey, so that a repointed alias takes effect. Data keys are decrypted by KMS with
+// RSAES_OAEP_SHA_256.
+//
+// The key ID is a key ARN or an alias ARN. The encrypted data is the data key followed by the
+// SHA-256 hash of the name of the secret, as KMS does not accept an encryption context for
+// asymmetric keys.
+type KmsRsa struct{}
+
+// NewKmsRsa returns a new KmsRsa.
+func NewKmsRsa() KeyManager {
+	return &KmsRsa{}
+}
+
+// kmsPublicKey is the cached result of GetPublicKey.
+type kmsPublicKey struct {
+	KeyID     string `json:"key_id"`
+	PublicKey []byte `json:"public_key"`
+}
+
+// GenerateEnvelopeKey generates a random data key and encrypts it with the public key of keyID.
+func (k *KmsRsa) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	cached, err := getKmsPublicKey(ctx, keyID)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	parsed, err := x509.ParsePKIXPublicKey(cached.PublicKey)
+	if err != nil {
+		return EnvelopeKey{}, fmt.Errorf("%s: invalid public key: %s", keyID, err)
+	}
+	publicKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return EnvelopeKey{}, fmt.Errorf("%s is not an RSA key.", keyID)
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return EnvelopeKey{}, err
+	}
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, bindSecret(dataKey, secretID), nil)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: cached.KeyID,
+		Plaintext:  dataKey,
+		Ciphertext: ciphertext}, nil
+}
+
+// Decrypt asks KMS to decrypt the data key with the private key of keyID. Like Kms, it uses the
+// replicas of a multi-Region key in the regions of WithRegionPriority first.
+func (k *KmsRsa) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	plaintext, err := decryptInRegions(ctx, keyID, func(regionalKeyID string) ([]byte, error) {
+		client, err := newKmsClient(ctx, regionalKeyID)
+		if err != nil {
+			return nil, err
+		}
+		do, err := client.Decrypt(ctx, &kms.DecryptInput{
+			KeyId:               aws.String(regionalKeyID),
+			CiphertextBlob:      keyCiphertext,
+			EncryptionAlgorithm: types.EncryptionAlgorithmSpecRsaesOaepSha256,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return do.Plaintext, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) != 32+sha256.Size || !bytes.Equal(plaintext, bindSecret(plaintext[:32], secretID)) {
+		return nil, errKmsRsaWrongSecret
+	}
+	return plaintext[:32], nil
+}
+
+// Label returns KmsRsaLabel.
+func (k *KmsRsa) Label() string {
+	return KmsRsaLabel
+}
+
+// getKmsPublicKey returns the public key of keyID from the cache, or else from KMS.
+func getKmsPublicKey(ctx context.Context, keyID string) (kmsPublicKey, error) {
+	kmsPublicKeysMu.Lock()
+	defer kmsPublicKeysMu.Unlock()
+	keyArn, err := resolveKmsKeyArn(ctx, keyID)
+	if err != nil {
+		return kmsPublicKey{}, err
+	}
+	if cached, ok := kmsPublicKeys[keyArn]; ok {
+		return cached, nil
+	}
+
+	path := kmsPublicKeyCachePath(keyArn)
+	if path != "" {
+		var cached kmsPublicKey
+		if contents, err := os.ReadFile(path); err == nil && json.Unmarshal(contents, &cached) == nil &&
+			cached.KeyID == keyArn {
+			kmsPublicKeys[keyArn] = cached
+			return cached, nil
+		}
+	}
+
+	client, err := newKmsClient(ctx, keyArn)
+	if err != nil {
+		return kmsPublicKey{}, err
+	}
+	output, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(keyArn)})
+	if err != nil {
+		return kmsPublicKey{}, err
+	}
+	if output.KeyUsage != types.KeyUsageTypeEncryptDecrypt || !supportsOaepSha256(output.EncryptionAlgorithms) {
+		return kmsPublicKey{}, fmt.Errorf("%s must be an RSA key with a key usage of ENCRYPT_DECRYPT.", keyID)
+	}
+	cached := kmsPublicKey{KeyID: keyArn, PublicKey: output.PublicKey}
+	kmsPublicKeys[keyArn] = cached
+	if path != "" {
+		// The cache only saves a call to KMS, so failing to write it is not an error.
+		if contents, err := json.Marshal(cached); err == nil && os.MkdirAll(filepath.Dir(path), 0700) == nil {
+			_ = os.WriteFile(path, contents, 0600)
+		}
+	}
+	return cached, nil
+}
+
+// resolveKmsKeyArn returns the ARN of the key that keyID names. Key ARNs are returned as they are.
+// Anything else, such as an alias, is resolved with DescribeKey once per process.
+func resolveKmsKeyArn(ctx context.Context, keyID string) (string, error) {
+	if parsed, err := arn.New(keyID); err == nil && parsed.IsKmsKey() {
+		return keyID, nil
+	}
+	if keyArn, ok := kmsKeyArns[keyID]; ok {
+		return keyArn, nil
+	}
+	client, err := newKmsClient(ctx, keyID)
+	if err != nil {
+		return "", err
+	}
+	output, err := client.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return "", err
+	}
+	keyArn := aws.ToString(output.KeyMetadata.Arn)
+	kmsKeyArns[keyID] = keyArn
+	return keyArn, nil
+}
+
+// kmsPublicKeyCachePath returns the path that the public key of the key keyArn is cached at, or ""
+// if there is no cache directory.
+func kmsPublicKeyCachePath(keyArn string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256([]byte(keyArn))
+	return filepath.Join(dir, KmsPublicKeyCacheDir, hex.EncodeToString(hash[:])+".json")
+}
+
+func supportsOaepSha256(algorithms []types.EncryptionAlgorithmSpec) bool {
+	for _, algorithm := range algorithms {
+		if algorithm == types.EncryptionAlgorithmSpecRsaesOaepSha256 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package keymanager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKmsRsaGenerateEnvelopeKey(t *testing.T) {
+	ctx := context.Background()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	public, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	assert.NoError(t, err)
+
+	// Public keys are cached by key ARN, and an alias resolved in this process is not resolved again,
+	// so neither calls KMS.
+	alias := "arn:aws:kms:us-west-2:111111111111:alias/biscuit-rsa"
+	resolved := "arn:aws:kms:us-west-2:111111111111:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	kmsPublicKeys[resolved] = kmsPublicKey{KeyID: resolved, PublicKey: public}
+	defer delete(kmsPublicKeys, resolved)
+	kmsKeyArns[alias] = resolved
+	defer delete(kmsKeyArns, alias)
+
+	manager, err := New(KmsRsaLabel)
+	assert.NoError(t, err)
+	key, err := manager.GenerateEnvelopeKey(ctx, alias, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, resolved, key.ResolvedID)
+	assert.Len(t, key.Plaintext, 32)
+
+	// KMS decrypts with RSAES_OAEP_SHA_256, which is OAEP with SHA-256 and no label.
+	payload, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, private, key.Ciphertext, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, bindSecret(key.Plaintext, "password"), payload)
+
+	key, err = manager.GenerateEnvelopeKey(ctx, resolved, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, resolved, key.ResolvedID)
+
+	// An alias that points to another key gets that key's public key.
+	other := "arn:aws:kms:us-west-2:111111111111:key/5678abcd-12ab-34cd-56ef-1234567890ab"
+	kmsKeyArns[alias] = other
+	kmsPublicKeys[other] = kmsPublicKey{KeyID: other, PublicKey: public}
+	defer delete(kmsPublicKeys, other)
+	key, err = manager.GenerateEnvelopeKey(ctx, alias, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, other, key.ResolvedID)
+}
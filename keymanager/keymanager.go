@@ -2,8 +2,10 @@ package keymanager
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"sort"
+	"strings"
 )
 
 var (
@@ -18,11 +20,15 @@ func (e *errUnsupportedKeyManager) Error() string {
 	return fmt.Sprintf("unsupported key manager '%s'", e.label)
 }
 
-// New returns a KeyManager of the requested type.
+// New returns a KeyManager of the requested type. A label of the form plugin:NAME returns a
+// Plugin.
 func New(label string) (KeyManager, error) {
 	if constructor, present := registry[label]; present {
 		return constructor(), nil
 	}
+	if plugin, ok := newPlugin(label); ok {
+		return plugin, nil
+	}
 	return nil, &errUnsupportedKeyManager{label}
 }
 
@@ -41,6 +47,26 @@ func GetKeyManagers() []string {
 	return collector
 }
 
+// SplitLabel separates a key given as LABEL:KEYID, where LABEL is the label of a key manager, into
+// the label and the key ID. The label may name a plugin, as in plugin:NAME:KEYID. Keys without such
+// a prefix use defaultLabel.
+func SplitLabel(key, defaultLabel string) (string, string) {
+	if strings.HasPrefix(key, PluginPrefix) {
+		if i := strings.Index(key[len(PluginPrefix):], ":"); i >= 0 {
+			label := key[:len(PluginPrefix)+i]
+			if _, ok := newPlugin(label); ok {
+				return label, key[len(label)+1:]
+			}
+		}
+	}
+	for label := range registry {
+		if strings.HasPrefix(key, label+":") {
+			return label, strings.TrimPrefix(key, label+":")
+		}
+	}
+	return defaultLabel, key
+}
+
 // KeyManager represents a service that can generate envelope keys and provide decryption
 // keys.
 type KeyManager interface {
@@ -66,3 +92,9 @@ func (e *EnvelopeKey) GetPlaintextKey() *[32]byte {
 	copy(plaintextArray[:], e.Plaintext)
 	return &plaintextArray
 }
+
+// bindSecret returns the data key followed by the hash of the name of the secret.
+func bindSecret(dataKey []byte, secretID string) []byte {
+	hash := sha256.Sum256([]byte(secretID))
+	return append(append([]byte(nil), dataKey...), hash[:]...)
+}
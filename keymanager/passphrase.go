@@ -0,0 +1,299 @@
+package keymanager
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	// PassphraseLabel is the label for the passphrase key manager.
+	PassphraseLabel = "passphrase"
+	// PassphraseEnv is the environment variable that the passphrase is read from. If it is not set,
+	// the passphrase is read from the terminal.
+	PassphraseEnv = "BISCUIT_PASSPHRASE"
+
+	argon2idKdf = "argon2id"
+	scryptKdf   = "scrypt"
+
+	// The parameters are read from the key ciphertext, so they are limited to keep a corrupted or
+	// crafted value from using unbounded memory or time.
+	maxKdfMemory  = 4 << 30 // bytes
+	maxKdfTime    = 64      // argon2id passes
+	maxKdfThreads = 64      // argon2id threads and scrypt parallelism
+)
+
+func init() {
+	registry[PassphraseLabel] = NewPassphrase
+}
+
+var (
+	errPassphraseNotAvailable = errors.New("Please set " + PassphraseEnv + ", or run biscuit in a " +
+		"terminal to enter the passphrase.")
+	errPassphraseMismatch  = errors.New("The passphrases do not match.")
+	errPassphraseEmpty     = errors.New("The passphrase must not be empty.")
+	errPassphraseIncorrect = errors.New("The passphrase is incorrect, or key_ciphertext is corrupted.")
+
+	// The passphrase is read once per process, as a single command may encrypt or decrypt many
+	// values.
+	passphraseMu sync.Mutex
+	passphrase   []byte
+)
+
+// Passphrase is a KeyManager that wraps each data key with a key derived from a passphrase, for use
+// without AWS. The key ID chooses the key derivation function and, optionally, its parameters:
+// "argon2id" or "argon2id:time=3;memory=65536;threads=4" (memory is in KiB), or "scrypt" or
+// "scrypt:n=32768;r=8;p=1". Settings are separated by semicolons because commas separate keys in
+// --key-id. The parameters and a random salt are stored in the key ciphertext, so
+// they can be changed without affecting existing values.
+type Passphrase struct{}
+
+// NewPassphrase returns a new Passphrase.
+func NewPassphrase() KeyManager {
+	return &Passphrase{}
+}
+
+// kdfParams are the settings of the key derivation function, stored with each wrapped key.
+type kdfParams struct {
+	KDF  string `json:"kdf"`
+	Salt []byte `json:"salt"`
+	// Time, Memory and Threads are the parameters of argon2id.
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+	// N, R and P are the parameters of scrypt.
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+}
+
+// wrappedKey is the key ciphertext of a value encrypted under a passphrase.
+type wrappedKey struct {
+	kdfParams
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// parseKdfParams returns the parameters named by a key ID, with defaults for any not given.
+func parseKdfParams(keyID string) (kdfParams, error) {
+	name, settings := keyID, ""
+	if i := strings.Index(keyID, ":"); i >= 0 {
+		name, settings = keyID[:i], keyID[i+1:]
+	}
+	var params kdfParams
+	switch name {
+	case argon2idKdf, "":
+		params = kdfParams{KDF: argon2idKdf, Time: 3, Memory: 64 * 1024, Threads: 4}
+	case scryptKdf:
+		params = kdfParams{KDF: scryptKdf, N: 32768, R: 8, P: 1}
+	default:
+		return params, fmt.Errorf("Unknown key derivation function %q. Use %s or %s.", name, argon2idKdf,
+			scryptKdf)
+	}
+	if settings != "" {
+		for _, setting := range strings.Split(settings, ";") {
+			pair := strings.SplitN(setting, "=", 2)
+			if len(pair) != 2 {
+				return params, fmt.Errorf("Invalid key derivation setting %q: expected NAME=VALUE.", setting)
+			}
+			n, err := strconv.ParseUint(pair[1], 10, 32)
+			if err != nil {
+				return params, fmt.Errorf("Invalid key derivation setting %q: %s", setting, err)
+			}
+			switch params.KDF + "." + pair[0] {
+			case "argon2id.time":
+				params.Time = uint32(n)
+			case "argon2id.memory":
+				params.Memory = uint32(n)
+			case "argon2id.threads":
+				if n > 255 {
+					return params, fmt.Errorf("Invalid key derivation setting %q: at most 255 threads.", setting)
+				}
+				params.Threads = uint8(n)
+			case "scrypt.n":
+				params.N = int(n)
+			case "scrypt.r":
+				params.R = int(n)
+			case "scrypt.p":
+				params.P = int(n)
+			default:
+				return params, fmt.Errorf("Unknown %s setting %q.", params.KDF, pair[0])
+			}
+		}
+	}
+	return params, params.validate()
+}
+
+// validate checks parameters that would otherwise make the key derivation functions panic, or use
+// more than maxKdfMemory bytes of memory or an unreasonable amount of time.
+func (p kdfParams) validate() error {
+	switch p.KDF {
+	case argon2idKdf:
+		if p.Time < 1 || p.Threads < 1 || p.Memory < 8*uint32(p.Threads) {
+			return errors.New("Invalid argon2id parameters: time and threads must be at least 1, and " +
+				"memory at least 8 KiB per thread.")
+		}
+		if p.Time > maxKdfTime || p.Threads > maxKdfThreads || uint64(p.Memory) > maxKdfMemory/1024 {
+			return fmt.Errorf("Invalid argon2id parameters: time must be at most %d, threads at most %d, "+
+				"and memory at most %d KiB.", maxKdfTime, maxKdfThreads, maxKdfMemory/1024)
+		}
+	case scryptKdf:
+		// scrypt.Key checks the other constraints on its parameters.
+		if p.N < 2 || p.R < 1 || p.P < 1 || p.P > maxKdfThreads || uint64(p.N) > maxKdfMemory/128/uint64(p.R) {
+			return fmt.Errorf("Invalid scrypt parameters: n must be at least 2, r and p at least 1, p at "+
+				"most %d, and 128*n*r at most %d bytes.", maxKdfThreads, maxKdfMemory)
+		}
+	default:
+		return fmt.Errorf("Unknown key derivation function %q.", p.KDF)
+	}
+	return nil
+}
+
+// deriveKey derives a 256-bit key-encryption key from passphrase.
+func (p kdfParams) deriveKey(passphrase []byte) ([]byte, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if p.KDF == scryptKdf {
+		return scrypt.Key(passphrase, p.Salt, p.N, p.R, p.P, 32)
+	}
+	return argon2.IDKey(passphrase, p.Salt, p.Time, p.Memory, p.Threads, 32), nil
+}
+
+// GenerateEnvelopeKey generates a random data key and wraps it with a key derived from the
+// passphrase. The passphrase is asked for twice if it is read from the terminal.
+func (p *Passphrase) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	params, err := parseKdfParams(keyID)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	secret, err := readPassphrase(true)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	wrapped := wrappedKey{kdfParams: params}
+	wrapped.Salt = make([]byte, 16)
+	dataKey := make([]byte, 32)
+	for _, random := range [][]byte{wrapped.Salt, dataKey} {
+		if _, err := rand.Read(random); err != nil {
+			return EnvelopeKey{}, err
+		}
+	}
+	aead, err := passphraseAEAD(wrapped.kdfParams, secret)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	wrapped.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(wrapped.Nonce); err != nil {
+		return EnvelopeKey{}, err
+	}
+	wrapped.Ciphertext = aead.Seal(nil, wrapped.Nonce, dataKey, []byte(secretID))
+	ciphertext, err := json.Marshal(wrapped)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: keyID,
+		Plaintext:  dataKey,
+		Ciphertext: ciphertext}, nil
+}
+
+// Decrypt unwraps the data key with a key derived from the passphrase, using the parameters stored
+// in keyCiphertext.
+func (p *Passphrase) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	var wrapped wrappedKey
+	if err := json.Unmarshal(keyCiphertext, &wrapped); err != nil {
+		return nil, fmt.Errorf("key_ciphertext is corrupted: %s", err)
+	}
+	if err := wrapped.validate(); err != nil {
+		return nil, fmt.Errorf("key_ciphertext is corrupted: %s", err)
+	}
+	secret, err := readPassphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := passphraseAEAD(wrapped.kdfParams, secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped.Nonce) != aead.NonceSize() {
+		return nil, errPassphraseIncorrect
+	}
+	dataKey, err := aead.Open(nil, wrapped.Nonce, wrapped.Ciphertext, []byte(secretID))
+	if err != nil {
+		return nil, errPassphraseIncorrect
+	}
+	return dataKey, nil
+}
+
+// Label returns PassphraseLabel.
+func (p *Passphrase) Label() string {
+	return PassphraseLabel
+}
+
+func passphraseAEAD(params kdfParams, secret []byte) (cipher.AEAD, error) {
+	key, err := params.deriveKey(secret)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readPassphrase returns the passphrase from PassphraseEnv, or else asks for it on the terminal. If
+// confirm is true, a passphrase entered on the terminal must be entered twice.
+func readPassphrase(confirm bool) ([]byte, error) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	if passphrase != nil {
+		return passphrase, nil
+	}
+	if value, ok := os.LookupEnv(PassphraseEnv); ok {
+		if value == "" {
+			return nil, errPassphraseEmpty
+		}
+		passphrase = []byte(value)
+		return passphrase, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, errPassphraseNotAvailable
+	}
+	prompts := []string{"Passphrase: "}
+	if confirm {
+		prompts = append(prompts, "Confirm passphrase: ")
+	}
+	var entered [][]byte
+	for _, prompt := range prompts {
+		fmt.Fprint(os.Stderr, prompt)
+		value, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		entered = append(entered, value)
+	}
+	if len(entered[0]) == 0 {
+		return nil, errPassphraseEmpty
+	}
+	if confirm && string(entered[0]) != string(entered[1]) {
+		return nil, errPassphraseMismatch
+	}
+	passphrase = entered[0]
+	return passphrase, nil
+}
@@ -0,0 +1,127 @@
+package keymanager
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// usePassphrase sets the passphrase that the passphrase key manager reads, and forgets the one it
+// read before.
+func usePassphrase(t *testing.T, value string) {
+	previous, set := os.LookupEnv(PassphraseEnv)
+	assert.NoError(t, os.Setenv(PassphraseEnv, value))
+	passphrase = nil
+	t.Cleanup(func() {
+		passphrase = nil
+		if set {
+			os.Setenv(PassphraseEnv, previous)
+		} else {
+			os.Unsetenv(PassphraseEnv)
+		}
+	})
+}
+
+func TestPassphrase(t *testing.T) {
+	ctx := context.Background()
+	for _, keyID := range []string{"argon2id:time=1;memory=64;threads=1", "scrypt:n=1024;r=8;p=1"} {
+		usePassphrase(t, "correct horse battery staple")
+		manager, err := New(PassphraseLabel)
+		assert.NoError(t, err)
+		key, err := manager.GenerateEnvelopeKey(ctx, keyID, "password")
+		assert.NoError(t, err, keyID)
+		assert.Equal(t, keyID, key.ResolvedID)
+		assert.Len(t, key.Plaintext, 32)
+
+		var wrapped wrappedKey
+		assert.NoError(t, json.Unmarshal(key.Ciphertext, &wrapped))
+		assert.Len(t, wrapped.Salt, 16)
+		assert.NotContains(t, string(key.Ciphertext), string(key.Plaintext))
+
+		plaintext, err := manager.Decrypt(ctx, keyID, key.Ciphertext, "password")
+		assert.NoError(t, err, keyID)
+		assert.Equal(t, key.Plaintext, plaintext)
+
+		// The wrapped key is bound to the name of the secret.
+		_, err = manager.Decrypt(ctx, keyID, key.Ciphertext, "username")
+		assert.Equal(t, errPassphraseIncorrect, err)
+
+		usePassphrase(t, "incorrect horse battery staple")
+		_, err = manager.Decrypt(ctx, keyID, key.Ciphertext, "password")
+		assert.Equal(t, errPassphraseIncorrect, err)
+	}
+}
+
+func TestPassphrase_salted(t *testing.T) {
+	ctx := context.Background()
+	usePassphrase(t, "correct horse battery staple")
+	manager := NewPassphrase()
+	first, err := manager.GenerateEnvelopeKey(ctx, "scrypt:n=1024", "password")
+	assert.NoError(t, err)
+	second, err := manager.GenerateEnvelopeKey(ctx, "scrypt:n=1024", "password")
+	assert.NoError(t, err)
+	var firstWrapped, secondWrapped wrappedKey
+	assert.NoError(t, json.Unmarshal(first.Ciphertext, &firstWrapped))
+	assert.NoError(t, json.Unmarshal(second.Ciphertext, &secondWrapped))
+	assert.NotEqual(t, firstWrapped.Salt, secondWrapped.Salt)
+	assert.NotEqual(t, first.Plaintext, second.Plaintext)
+}
+
+func TestPassphrase_empty(t *testing.T) {
+	usePassphrase(t, "")
+	_, err := NewPassphrase().GenerateEnvelopeKey(context.Background(), "scrypt:n=1024", "password")
+	assert.Equal(t, errPassphraseEmpty, err)
+}
+
+func TestParseKdfParams(t *testing.T) {
+	params, err := parseKdfParams("argon2id")
+	assert.NoError(t, err)
+	assert.Equal(t, kdfParams{KDF: argon2idKdf, Time: 3, Memory: 65536, Threads: 4}, params)
+
+	params, err = parseKdfParams("")
+	assert.NoError(t, err)
+	assert.Equal(t, argon2idKdf, params.KDF)
+
+	params, err = parseKdfParams("argon2id:time=2;memory=1024")
+	assert.NoError(t, err)
+	assert.Equal(t, kdfParams{KDF: argon2idKdf, Time: 2, Memory: 1024, Threads: 4}, params)
+
+	params, err = parseKdfParams("scrypt:n=65536;p=2")
+	assert.NoError(t, err)
+	assert.Equal(t, kdfParams{KDF: scryptKdf, N: 65536, R: 8, P: 2}, params)
+
+	for _, invalid := range []string{"pbkdf2", "argon2id:time=0", "argon2id:threads=300",
+		"argon2id:memory=1", "argon2id:n=1", "scrypt:time=1", "scrypt:n", "scrypt:n=-1",
+		"argon2id:memory=4294967295", "argon2id:time=1000000", "scrypt:n=1073741824", "scrypt:p=1000"} {
+		_, err := parseKdfParams(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPassphrase_oversizedParameters(t *testing.T) {
+	ctx := context.Background()
+	usePassphrase(t, "correct horse battery staple")
+	manager := NewPassphrase()
+	key, err := manager.GenerateEnvelopeKey(ctx, "scrypt:n=1024", "password")
+	assert.NoError(t, err)
+
+	// A corrupted or crafted value must fail before the key derivation function allocates memory.
+	for _, params := range []kdfParams{
+		{KDF: argon2idKdf, Time: 1, Memory: 4294967295, Threads: 1},
+		{KDF: scryptKdf, N: 1 << 30, R: 8, P: 1},
+		{KDF: scryptKdf, N: 1024, R: 1 << 30, P: 1},
+	} {
+		var wrapped wrappedKey
+		assert.NoError(t, json.Unmarshal(key.Ciphertext, &wrapped))
+		params.Salt = wrapped.Salt
+		wrapped.kdfParams = params
+		crafted, err := json.Marshal(wrapped)
+		assert.NoError(t, err)
+		_, err = manager.Decrypt(ctx, "scrypt", crafted, "password")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "corrupted")
+	}
+}
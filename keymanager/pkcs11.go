@@ -0,0 +1,318 @@
+//go:build cgo
+// +build cgo
+
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+const (
+	// Pkcs11Label is the label for the PKCS#11 key manager.
+	Pkcs11Label = "pkcs11"
+	// Pkcs11PinEnv is the environment variable that holds the PIN of the token's user.
+	Pkcs11PinEnv = "BISCUIT_PKCS11_PIN"
+
+	aesKeyWrapMechanism = "aes-key-wrap"
+	rsaOaepMechanism    = "rsa-oaep"
+)
+
+func init() {
+	registry[Pkcs11Label] = NewPkcs11
+}
+
+var (
+	errPkcs11WrongSecret = errors.New("key_ciphertext was encrypted for a different secret")
+
+	// Each module is loaded and initialized once per process.
+	pkcs11ModulesMu sync.Mutex
+	pkcs11Modules   = make(map[string]*pkcs11.Ctx)
+)
+
+// Pkcs11 is a KeyManager that wraps data keys with a key held by a PKCS#11 token such as an HSM.
+// The key ID names the module, the token and the key as semicolon-separated settings:
+//
+//	module=/usr/lib/softhsm/libsofthsm2.so;slot=0;label=biscuit
+//	module=/usr/lib/softhsm/libsofthsm2.so;token=biscuit;label=biscuit;mechanism=rsa-oaep
+//
+// The token is found by slot ID or by token label. If the label names an AES key, data keys are
+// wrapped with AES key wrap (RFC 3394); if it names an RSA key pair, they are encrypted with
+// RSA-OAEP. mechanism may be set to aes-key-wrap or rsa-oaep to insist on one. The user's PIN is read
+// from BISCUIT_PKCS11_PIN.
+//
+// The wrapped data is the data key followed by the SHA-256 hash of the name of the secret, which is
+// checked when it is unwrapped.
+type Pkcs11 struct{}
+
+// NewPkcs11 returns a new Pkcs11.
+func NewPkcs11() KeyManager {
+	return &Pkcs11{}
+}
+
+// pkcs11Key is a parsed key ID.
+type pkcs11Key struct {
+	module    string
+	slot      *uint
+	token     string
+	label     string
+	mechanism string
+}
+
+func parsePkcs11Key(keyID string) (pkcs11Key, error) {
+	var key pkcs11Key
+	for _, setting := range strings.Split(keyID, ";") {
+		pair := strings.SplitN(setting, "=", 2)
+		if len(pair) != 2 {
+			return key, fmt.Errorf("Invalid PKCS#11 key setting %q: expected NAME=VALUE.", setting)
+		}
+		switch pair[0] {
+		case "module":
+			key.module = pair[1]
+		case "slot":
+			slot, err := strconv.ParseUint(pair[1], 10, 0)
+			if err != nil {
+				return key, fmt.Errorf("Invalid PKCS#11 slot %q: %s", pair[1], err)
+			}
+			s := uint(slot)
+			key.slot = &s
+		case "token":
+			key.token = pair[1]
+		case "label":
+			key.label = pair[1]
+		case "mechanism":
+			if pair[1] != aesKeyWrapMechanism && pair[1] != rsaOaepMechanism {
+				return key, fmt.Errorf("Unknown PKCS#11 mechanism %q. Use %s or %s.", pair[1],
+					aesKeyWrapMechanism, rsaOaepMechanism)
+			}
+			key.mechanism = pair[1]
+		default:
+			return key, fmt.Errorf("Unknown PKCS#11 key setting %q.", pair[0])
+		}
+	}
+	if key.module == "" || key.label == "" || (key.slot == nil && key.token == "") {
+		return key, fmt.Errorf("Invalid PKCS#11 key ID %q: module, label, and slot or token are required.", keyID)
+	}
+	return key, nil
+}
+
+// GenerateEnvelopeKey generates a random data key and wraps it with the key on the token.
+func (p *Pkcs11) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return EnvelopeKey{}, err
+	}
+	var ciphertext []byte
+	err := withPkcs11Session(keyID, func(c *pkcs11.Ctx, session pkcs11.SessionHandle, key pkcs11Key) error {
+		mechanism, handle, err := findWrappingKey(c, session, key, true)
+		if err != nil {
+			return err
+		}
+		payload := bindSecret(dataKey, secretID)
+		if mechanism == rsaOaepMechanism {
+			if err := c.EncryptInit(session, oaepMechanism(), handle); err != nil {
+				return err
+			}
+			ciphertext, err = c.Encrypt(session, payload)
+			return err
+		}
+		object, err := c.CreateObject(session, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, payload),
+		})
+		if err != nil {
+			return err
+		}
+		defer c.DestroyObject(session, object)
+		ciphertext, err = c.WrapKey(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_WRAP, nil)},
+			handle, object)
+		return err
+	})
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: keyID,
+		Plaintext:  dataKey,
+		Ciphertext: ciphertext}, nil
+}
+
+// Decrypt unwraps the data key with the key on the token.
+func (p *Pkcs11) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	var payload []byte
+	err := withPkcs11Session(keyID, func(c *pkcs11.Ctx, session pkcs11.SessionHandle, key pkcs11Key) error {
+		mechanism, handle, err := findWrappingKey(c, session, key, false)
+		if err != nil {
+			return err
+		}
+		if mechanism == rsaOaepMechanism {
+			if err := c.DecryptInit(session, oaepMechanism(), handle); err != nil {
+				return err
+			}
+			payload, err = c.Decrypt(session, keyCiphertext)
+			return err
+		}
+		object, err := c.UnwrapKey(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_WRAP, nil)},
+			handle, keyCiphertext, []*pkcs11.Attribute{
+				pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+				pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+				pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+				pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, false),
+				pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+			})
+		if err != nil {
+			return err
+		}
+		defer c.DestroyObject(session, object)
+		attributes, err := c.GetAttributeValue(session, object, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+		})
+		if err != nil {
+			return err
+		}
+		payload = attributes[0].Value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 32+sha256.Size || !bytes.Equal(payload, bindSecret(payload[:32], secretID)) {
+		return nil, errPkcs11WrongSecret
+	}
+	return payload[:32], nil
+}
+
+// Label returns Pkcs11Label.
+func (p *Pkcs11) Label() string {
+	return Pkcs11Label
+}
+
+// oaepMechanism is RSA-OAEP with SHA-1 and no label, the variant that tokens most widely support.
+func oaepMechanism() []*pkcs11.Mechanism {
+	params := pkcs11.NewOAEPParams(pkcs11.CKM_SHA_1, pkcs11.CKG_MGF1_SHA1, pkcs11.CKZ_DATA_SPECIFIED, nil)
+	return []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_OAEP, params)}
+}
+
+// withPkcs11Session opens a session on the token named by keyID, logs in, and calls fn.
+func withPkcs11Session(keyID string, fn func(*pkcs11.Ctx, pkcs11.SessionHandle, pkcs11Key) error) error {
+	key, err := parsePkcs11Key(keyID)
+	if err != nil {
+		return err
+	}
+	pin, ok := os.LookupEnv(Pkcs11PinEnv)
+	if !ok {
+		return fmt.Errorf("Please set %s to the PIN of the PKCS#11 token.", Pkcs11PinEnv)
+	}
+	c, err := loadPkcs11Module(key.module)
+	if err != nil {
+		return err
+	}
+	slot, err := findPkcs11Slot(c, key)
+	if err != nil {
+		return err
+	}
+	session, err := c.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return err
+	}
+	defer c.CloseSession(session)
+	if err := c.Login(session, pkcs11.CKU_USER, pin); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		return err
+	}
+	return fn(c, session, key)
+}
+
+func loadPkcs11Module(module string) (*pkcs11.Ctx, error) {
+	pkcs11ModulesMu.Lock()
+	defer pkcs11ModulesMu.Unlock()
+	if c, ok := pkcs11Modules[module]; ok {
+		return c, nil
+	}
+	c := pkcs11.New(module)
+	if c == nil {
+		return nil, fmt.Errorf("Could not load the PKCS#11 module %s.", module)
+	}
+	if err := c.Initialize(); err != nil && err != pkcs11.Error(pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		c.Destroy()
+		return nil, err
+	}
+	pkcs11Modules[module] = c
+	return c, nil
+}
+
+func findPkcs11Slot(c *pkcs11.Ctx, key pkcs11Key) (uint, error) {
+	if key.slot != nil {
+		return *key.slot, nil
+	}
+	slots, err := c.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+	for _, slot := range slots {
+		info, err := c.GetTokenInfo(slot)
+		if err != nil {
+			return 0, err
+		}
+		if strings.TrimRight(info.Label, " \x00") == key.token {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("PKCS#11 token %q not found.", key.token)
+}
+
+// findWrappingKey returns the mechanism to use and the handle of the key labelled key.label: the AES
+// key, or the public or private half of the RSA key pair depending on whether the data key is being
+// wrapped.
+func findWrappingKey(c *pkcs11.Ctx, session pkcs11.SessionHandle, key pkcs11Key, wrap bool) (string, pkcs11.ObjectHandle, error) {
+	candidates := []struct {
+		mechanism string
+		class     uint
+	}{
+		{aesKeyWrapMechanism, pkcs11.CKO_SECRET_KEY},
+		{rsaOaepMechanism, pkcs11.CKO_PRIVATE_KEY},
+	}
+	if wrap {
+		candidates[1].class = pkcs11.CKO_PUBLIC_KEY
+	}
+	for _, candidate := range candidates {
+		if key.mechanism != "" && key.mechanism != candidate.mechanism {
+			continue
+		}
+		handle, found, err := findPkcs11Object(c, session, candidate.class, key.label)
+		if err != nil {
+			return "", 0, err
+		}
+		if found {
+			return candidate.mechanism, handle, nil
+		}
+	}
+	return "", 0, fmt.Errorf("PKCS#11 key %q not found.", key.label)
+}
+
+func findPkcs11Object(c *pkcs11.Ctx, session pkcs11.SessionHandle, class uint, label string) (pkcs11.ObjectHandle, bool, error) {
+	if err := c.FindObjectsInit(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}); err != nil {
+		return 0, false, err
+	}
+	defer c.FindObjectsFinal(session)
+	handles, _, err := c.FindObjects(session, 1)
+	if err != nil || len(handles) == 0 {
+		return 0, false, err
+	}
+	return handles[0], true, nil
+}
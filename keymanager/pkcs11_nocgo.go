@@ -0,0 +1,44 @@
+//go:build !cgo
+// +build !cgo
+
+package keymanager
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	// Pkcs11Label is the label for the PKCS#11 key manager.
+	Pkcs11Label = "pkcs11"
+)
+
+func init() {
+	registry[Pkcs11Label] = NewPkcs11
+}
+
+var errPkcs11Unavailable = errors.New("This build of biscuit was made without cgo; PKCS#11 is unavailable.")
+
+// Pkcs11 stands in for the PKCS#11 key manager, which needs cgo, so that using it explains why it
+// fails.
+type Pkcs11 struct{}
+
+// NewPkcs11 returns a new Pkcs11.
+func NewPkcs11() KeyManager {
+	return &Pkcs11{}
+}
+
+// GenerateEnvelopeKey returns an error.
+func (p *Pkcs11) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	return EnvelopeKey{}, errPkcs11Unavailable
+}
+
+// Decrypt returns an error.
+func (p *Pkcs11) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	return nil, errPkcs11Unavailable
+}
+
+// Label returns Pkcs11Label.
+func (p *Pkcs11) Label() string {
+	return Pkcs11Label
+}
@@ -0,0 +1,150 @@
+//go:build cgo
+// +build cgo
+
+package keymanager
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	softHSMToken = "biscuit-test"
+	softHSMPin   = "1234"
+)
+
+// softHSMModule returns the path of the SoftHSMv2 module, from SOFTHSM2_MODULE or a usual place, or
+// skips the test if it is not installed.
+func softHSMModule(t *testing.T) string {
+	candidates := []string{
+		os.Getenv("SOFTHSM2_MODULE"),
+		"/usr/lib/softhsm/libsofthsm2.so",
+		"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+		"/usr/local/lib/softhsm/libsofthsm2.so",
+		"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); candidate != "" && err == nil {
+			return candidate
+		}
+	}
+	t.Skip("SoftHSMv2 is not installed; set SOFTHSM2_MODULE to its module to run this test.")
+	return ""
+}
+
+// newSoftHSMToken creates a token in a temporary SoftHSM store, with an AES key labelled "aes" and
+// an RSA key pair labelled "rsa".
+func newSoftHSMToken(t *testing.T) string {
+	module := softHSMModule(t)
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "tokens"), 0700))
+	require.NoError(t, os.WriteFile(conf, []byte("directories.tokendir = "+filepath.Join(dir, "tokens")+"\n"), 0600))
+	require.NoError(t, os.Setenv("SOFTHSM2_CONF", conf))
+	require.NoError(t, os.Setenv(Pkcs11PinEnv, softHSMPin))
+	t.Cleanup(func() {
+		os.Unsetenv("SOFTHSM2_CONF")
+		os.Unsetenv(Pkcs11PinEnv)
+	})
+
+	c, err := loadPkcs11Module(module)
+	require.NoError(t, err)
+	slots, err := c.GetSlotList(false)
+	require.NoError(t, err)
+	require.NoError(t, c.InitToken(slots[0], "so-pin", softHSMToken))
+	slot, err := findPkcs11Slot(c, pkcs11Key{token: softHSMToken})
+	require.NoError(t, err)
+
+	session, err := c.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	require.NoError(t, err)
+	defer c.CloseSession(session)
+	require.NoError(t, c.Login(session, pkcs11.CKU_SO, "so-pin"))
+	require.NoError(t, c.InitPIN(session, softHSMPin))
+	require.NoError(t, c.Logout(session))
+	require.NoError(t, c.Login(session, pkcs11.CKU_USER, softHSMPin))
+	_, err = c.GenerateKey(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "aes"),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+			pkcs11.NewAttribute(pkcs11.CKA_WRAP, true),
+			pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
+		})
+	require.NoError(t, err)
+	_, _, err = c.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "rsa"),
+			pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "rsa"),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		})
+	require.NoError(t, err)
+	return module
+}
+
+func TestPkcs11(t *testing.T) {
+	ctx := context.Background()
+	module := newSoftHSMToken(t)
+	manager, err := New(Pkcs11Label)
+	require.NoError(t, err)
+
+	for _, label := range []string{"aes", "rsa"} {
+		keyID := "module=" + module + ";token=" + softHSMToken + ";label=" + label
+		key, err := manager.GenerateEnvelopeKey(ctx, keyID, "password")
+		require.NoError(t, err, label)
+		assert.Equal(t, keyID, key.ResolvedID)
+		assert.Len(t, key.Plaintext, 32)
+		assert.NotContains(t, string(key.Ciphertext), string(key.Plaintext))
+
+		plaintext, err := manager.Decrypt(ctx, keyID, key.Ciphertext, "password")
+		assert.NoError(t, err, label)
+		assert.Equal(t, key.Plaintext, plaintext, label)
+
+		// The wrapped key is bound to the name of the secret.
+		_, err = manager.Decrypt(ctx, keyID, key.Ciphertext, "username")
+		assert.Equal(t, errPkcs11WrongSecret, err, label)
+	}
+
+	_, err = manager.GenerateEnvelopeKey(ctx, "module="+module+";token="+softHSMToken+";label=aes;mechanism=rsa-oaep",
+		"password")
+	assert.Error(t, err)
+	_, err = manager.GenerateEnvelopeKey(ctx, "module="+module+";token=nope;label=aes", "password")
+	assert.Error(t, err)
+}
+
+func TestParsePkcs11Key(t *testing.T) {
+	key, err := parsePkcs11Key("module=/lib/softhsm2.so;slot=3;label=biscuit")
+	assert.NoError(t, err)
+	assert.Equal(t, "/lib/softhsm2.so", key.module)
+	assert.Equal(t, uint(3), *key.slot)
+	assert.Equal(t, "biscuit", key.label)
+
+	key, err = parsePkcs11Key("module=C:\\softhsm2.dll;token=hsm;label=biscuit;mechanism=rsa-oaep")
+	assert.NoError(t, err)
+	assert.Equal(t, "C:\\softhsm2.dll", key.module)
+	assert.Nil(t, key.slot)
+	assert.Equal(t, "hsm", key.token)
+	assert.Equal(t, rsaOaepMechanism, key.mechanism)
+
+	for _, invalid := range []string{"", "module=/lib/softhsm2.so;label=biscuit", "module=/lib/softhsm2.so;slot=0",
+		"slot=0;label=biscuit", "module=/lib/softhsm2.so;slot=x;label=biscuit", "module=/lib/softhsm2.so;slot=0;label=biscuit;pin=1",
+		"module=/lib/softhsm2.so;slot=0;label=biscuit;mechanism=des"} {
+		_, err := parsePkcs11Key(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,199 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+const (
+	// PluginPrefix is the prefix of the label of a plugin key manager, which is followed by the
+	// name of the plugin.
+	PluginPrefix = "plugin:"
+	// PluginCommandPrefix is the prefix of the command that implements a plugin, which is followed by
+	// the name of the plugin.
+	PluginCommandPrefix = "biscuit-keymanager-"
+	// PluginVersion is the version of the plugin protocol.
+	PluginVersion = 1
+
+	// PluginGenerate is the method that generates a data key.
+	PluginGenerate = "generate"
+	// PluginDecrypt is the method that decrypts a data key.
+	PluginDecrypt = "decrypt"
+)
+
+var pluginName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// PluginRequest is the request that biscuit writes to the standard input of a plugin. Byte slices
+// are base64 encoded.
+type PluginRequest struct {
+	Version int    `json:"version"`
+	Method  string `json:"method"`
+	KeyID   string `json:"key_id"`
+	// SecretID is the name of the secret. A plugin must bind the data key to it, so that a key
+	// ciphertext cannot be decrypted for a different secret.
+	SecretID string `json:"secret_id"`
+	// KeyCiphertext is the key ciphertext to decrypt. It is only set for PluginDecrypt.
+	KeyCiphertext []byte `json:"key_ciphertext,omitempty"`
+}
+
+// PluginResponse is the response that a plugin writes to its standard output. Byte slices are
+// base64 encoded.
+type PluginResponse struct {
+	// Error describes why the request failed. If it is set, the other fields are ignored.
+	Error string `json:"error,omitempty"`
+	// KeyID is the fully qualified key ID, returned by PluginGenerate. If it is empty, the key ID
+	// of the request is used.
+	KeyID string `json:"key_id,omitempty"`
+	// Plaintext is the 32-byte data key.
+	Plaintext []byte `json:"plaintext,omitempty"`
+	// KeyCiphertext is the encrypted data key, returned by PluginGenerate.
+	KeyCiphertext []byte `json:"key_ciphertext,omitempty"`
+}
+
+// Plugin is a KeyManager implemented by another program, so that teams can bring their own key
+// management service without changing biscuit. The plugin named NAME, with the label plugin:NAME,
+// is the command biscuit-keymanager-NAME on the PATH. For each operation, biscuit runs the command,
+// writes a PluginRequest to its standard input and reads a PluginResponse from its standard output.
+// Anything the command writes to standard error is shown to the user.
+type Plugin struct {
+	name string
+}
+
+// NewPlugin returns a new Plugin for the plugin called name.
+func NewPlugin(name string) KeyManager {
+	return &Plugin{name: name}
+}
+
+// GenerateEnvelopeKey asks the plugin for a new data key under keyID.
+func (p *Plugin) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	response, err := p.call(ctx, PluginRequest{Method: PluginGenerate, KeyID: keyID, SecretID: secretID})
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	if len(response.KeyCiphertext) == 0 {
+		return EnvelopeKey{}, fmt.Errorf("%s: the plugin did not return a key ciphertext", p.Label())
+	}
+	if response.KeyID == "" {
+		response.KeyID = keyID
+	}
+	return EnvelopeKey{
+		ResolvedID: response.KeyID,
+		Plaintext:  response.Plaintext,
+		Ciphertext: response.KeyCiphertext}, nil
+}
+
+// Decrypt asks the plugin to decrypt the data key.
+func (p *Plugin) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	response, err := p.call(ctx, PluginRequest{Method: PluginDecrypt, KeyID: keyID, SecretID: secretID,
+		KeyCiphertext: keyCiphertext})
+	if err != nil {
+		return nil, err
+	}
+	return response.Plaintext, nil
+}
+
+// Label returns PluginPrefix followed by the name of the plugin.
+func (p *Plugin) Label() string {
+	return PluginPrefix + p.name
+}
+
+// call runs the plugin with request, and returns its response if it contains a data key.
+func (p *Plugin) call(ctx context.Context, request PluginRequest) (PluginResponse, error) {
+	var response PluginResponse
+	command := PluginCommandPrefix + p.name
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return response, fmt.Errorf("The %s key manager needs %s on your PATH: %s", p.Label(), command, err)
+	}
+	request.Version = PluginVersion
+	input, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+	if err := json.Unmarshal(output.Bytes(), &response); err != nil {
+		if runErr != nil {
+			return response, fmt.Errorf("%s: %s", p.Label(), runErr)
+		}
+		return response, fmt.Errorf("%s: the plugin returned an invalid response: %s", p.Label(), err)
+	}
+	if response.Error != "" {
+		return response, fmt.Errorf("%s: %s", p.Label(), response.Error)
+	}
+	if runErr != nil {
+		return response, fmt.Errorf("%s: %s", p.Label(), runErr)
+	}
+	if len(response.Plaintext) != 32 {
+		return response, fmt.Errorf("%s: the plugin returned a data key of %d bytes instead of 32",
+			p.Label(), len(response.Plaintext))
+	}
+	return response, nil
+}
+
+// newPlugin returns the Plugin for a label of the form plugin:NAME.
+func newPlugin(label string) (KeyManager, bool) {
+	name := strings.TrimPrefix(label, PluginPrefix)
+	if name == label || !pluginName.MatchString(name) {
+		return nil, false
+	}
+	return NewPlugin(name), true
+}
+
+// ServePlugin handles one PluginRequest, read from r, with manager, and writes the PluginResponse
+// to w. It lets a KeyManager written in Go be used as a plugin:
+//
+//	func main() {
+//		if err := keymanager.ServePlugin(context.Background(), myKeyManager, os.Stdin, os.Stdout); err != nil {
+//			os.Exit(1)
+//		}
+//	}
+//
+// The error is that of the request, which has also been written to w.
+func ServePlugin(ctx context.Context, manager KeyManager, r io.Reader, w io.Writer) error {
+	response, err := servePlugin(ctx, manager, r)
+	if err != nil {
+		response = PluginResponse{Error: err.Error()}
+	}
+	if encodeErr := json.NewEncoder(w).Encode(response); encodeErr != nil {
+		return encodeErr
+	}
+	return err
+}
+
+func servePlugin(ctx context.Context, manager KeyManager, r io.Reader) (PluginResponse, error) {
+	var request PluginRequest
+	if err := json.NewDecoder(r).Decode(&request); err != nil {
+		return PluginResponse{}, fmt.Errorf("invalid request: %s", err)
+	}
+	if request.Version != PluginVersion {
+		return PluginResponse{}, fmt.Errorf("unsupported protocol version %d", request.Version)
+	}
+	switch request.Method {
+	case PluginGenerate:
+		key, err := manager.GenerateEnvelopeKey(ctx, request.KeyID, request.SecretID)
+		if err != nil {
+			return PluginResponse{}, err
+		}
+		return PluginResponse{KeyID: key.ResolvedID, Plaintext: key.Plaintext, KeyCiphertext: key.Ciphertext}, nil
+	case PluginDecrypt:
+		plaintext, err := manager.Decrypt(ctx, request.KeyID, request.KeyCiphertext, request.SecretID)
+		if err != nil {
+			return PluginResponse{}, err
+		}
+		return PluginResponse{Plaintext: plaintext}, nil
+	}
+	return PluginResponse{}, errors.New("unknown method " + request.Method)
+}
@@ -0,0 +1,25 @@
+package keymanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginLabels(t *testing.T) {
+	manager, err := New("plugin:my-kms")
+	assert.NoError(t, err)
+	assert.Equal(t, "plugin:my-kms", manager.Label())
+
+	for _, label := range []string{"plugin:", "plugin:../kms", "plugin:my/kms", "plugin:-kms"} {
+		_, err := New(label)
+		assert.Error(t, err, label)
+	}
+
+	label, keyID := SplitLabel("plugin:my-kms:key:1", KmsLabel)
+	assert.Equal(t, "plugin:my-kms", label)
+	assert.Equal(t, "key:1", keyID)
+	label, keyID = SplitLabel("plugin:my/kms:key", KmsLabel)
+	assert.Equal(t, KmsLabel, label)
+	assert.Equal(t, "plugin:my/kms:key", keyID)
+}
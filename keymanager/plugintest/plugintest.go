@@ -0,0 +1,101 @@
+// Package plugintest checks that a key manager plugin follows the biscuit plugin protocol. A plugin
+// author runs it from a test with the plugin's command on the PATH:
+//
+//	func TestConformance(t *testing.T) {
+//		plugintest.Run(t, "mykms", "my-key-id")
+//	}
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"testing"
+
+	"github.com/dcoker/biscuit/keymanager"
+)
+
+// Run checks that the plugin called name, run as keymanager.PluginCommandPrefix followed by name,
+// follows the protocol when generating and decrypting data keys under keyID.
+func Run(t *testing.T, name, keyID string) {
+	ctx := context.Background()
+	manager, err := keymanager.New(keymanager.PluginPrefix + name)
+	if err != nil {
+		t.Fatalf("invalid plugin name %q: %s", name, err)
+	}
+
+	key, err := manager.GenerateEnvelopeKey(ctx, keyID, "password")
+	if err != nil {
+		t.Fatalf("generate: %s", err)
+	}
+	if key.ResolvedID == "" {
+		t.Errorf("generate: empty key ID")
+	}
+
+	t.Run("generate returns a different key each time", func(t *testing.T) {
+		other, err := manager.GenerateEnvelopeKey(ctx, keyID, "password")
+		if err != nil {
+			t.Fatalf("generate: %s", err)
+		}
+		if bytes.Equal(key.Plaintext, other.Plaintext) {
+			t.Errorf("generate returned the same data key twice")
+		}
+	})
+
+	t.Run("decrypt returns the generated key", func(t *testing.T) {
+		plaintext, err := manager.Decrypt(ctx, key.ResolvedID, key.Ciphertext, "password")
+		if err != nil {
+			t.Fatalf("decrypt: %s", err)
+		}
+		if !bytes.Equal(key.Plaintext, plaintext) {
+			t.Errorf("decrypt returned a different data key")
+		}
+	})
+
+	t.Run("decrypt fails for a different secret", func(t *testing.T) {
+		if _, err := manager.Decrypt(ctx, key.ResolvedID, key.Ciphertext, "username"); err == nil {
+			t.Errorf("decrypt succeeded for a different secret ID")
+		}
+	})
+
+	t.Run("decrypt fails for a modified key ciphertext", func(t *testing.T) {
+		modified := append([]byte(nil), key.Ciphertext...)
+		modified[len(modified)-1] ^= 1
+		if _, err := manager.Decrypt(ctx, key.ResolvedID, modified, "password"); err == nil {
+			t.Errorf("decrypt succeeded for a modified key ciphertext")
+		}
+	})
+
+	t.Run("unknown methods fail", func(t *testing.T) {
+		checkRejected(t, name, keymanager.PluginRequest{Version: keymanager.PluginVersion, Method: "unknown",
+			KeyID: keyID, SecretID: "password"})
+	})
+
+	t.Run("unknown versions fail", func(t *testing.T) {
+		checkRejected(t, name, keymanager.PluginRequest{Version: keymanager.PluginVersion + 1,
+			Method: keymanager.PluginGenerate, KeyID: keyID, SecretID: "password"})
+	})
+}
+
+// checkRejected checks that the plugin fails request, either by exiting with an error or by
+// returning a response with an error.
+func checkRejected(t *testing.T, name string, request keymanager.PluginRequest) {
+	input, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(keymanager.PluginCommandPrefix + name)
+	cmd.Stdin = bytes.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		return
+	}
+	var response keymanager.PluginResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		t.Fatalf("invalid response %q: %s", output, err)
+	}
+	if response.Error == "" {
+		t.Errorf("the plugin accepted %+v", request)
+	}
+}
@@ -0,0 +1,122 @@
+package plugintest_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dcoker/biscuit/keymanager"
+	"github.com/dcoker/biscuit/keymanager/plugintest"
+	"github.com/stretchr/testify/assert"
+)
+
+// servePluginEnv makes the test binary act as the plugin rather than run the tests.
+const servePluginEnv = "BISCUIT_PLUGINTEST_SERVE"
+
+// TestMain installs the test binary as biscuit-keymanager-fake on the PATH.
+func TestMain(m *testing.M) {
+	if os.Getenv(servePluginEnv) != "" {
+		if err := keymanager.ServePlugin(context.Background(), &fakeKeys{}, os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(install(m))
+}
+
+func install(m *testing.M) int {
+	dir, err := os.MkdirTemp("", "plugintest")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	self, err := os.Executable()
+	if err != nil {
+		panic(err)
+	}
+	executable, err := os.ReadFile(self)
+	if err != nil {
+		panic(err)
+	}
+	name := keymanager.PluginCommandPrefix + "fake"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), executable, 0755); err != nil {
+		panic(err)
+	}
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	os.Setenv(servePluginEnv, "1")
+	return m.Run()
+}
+
+// fakeKeys encrypts data keys with AES-GCM under a key that is the key ID repeated, using the secret
+// ID as additional data.
+type fakeKeys struct{}
+
+func (f *fakeKeys) aead(keyID string) (cipher.AEAD, error) {
+	if keyID == "" {
+		return nil, errors.New("empty key ID")
+	}
+	block, err := aes.NewCipher(bytes.Repeat([]byte(keyID), 32)[:32])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (f *fakeKeys) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (keymanager.EnvelopeKey, error) {
+	aead, err := f.aead(keyID)
+	if err != nil {
+		return keymanager.EnvelopeKey{}, err
+	}
+	random := make([]byte, 32+aead.NonceSize())
+	if _, err := rand.Read(random); err != nil {
+		return keymanager.EnvelopeKey{}, err
+	}
+	dataKey, nonce := random[:32], random[32:]
+	return keymanager.EnvelopeKey{
+		ResolvedID: keyID,
+		Plaintext:  dataKey,
+		Ciphertext: aead.Seal(nonce, nonce, dataKey, []byte(secretID))}, nil
+}
+
+func (f *fakeKeys) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	aead, err := f.aead(keyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(keyCiphertext) < aead.NonceSize() {
+		return nil, errors.New("key ciphertext is too short")
+	}
+	nonce := keyCiphertext[:aead.NonceSize()]
+	return aead.Open(nil, nonce, keyCiphertext[aead.NonceSize():], []byte(secretID))
+}
+
+func (f *fakeKeys) Label() string {
+	return "fake"
+}
+
+func TestRun(t *testing.T) {
+	plugintest.Run(t, "fake", "key")
+}
+
+func TestPluginErrors(t *testing.T) {
+	ctx := context.Background()
+	manager, err := keymanager.New(keymanager.PluginPrefix + "fake")
+	assert.NoError(t, err)
+	_, err = manager.GenerateEnvelopeKey(ctx, "", "password")
+	assert.EqualError(t, err, "plugin:fake: empty key ID")
+
+	missing, err := keymanager.New(keymanager.PluginPrefix + "missing")
+	assert.NoError(t, err)
+	_, err = missing.GenerateEnvelopeKey(ctx, "key", "password")
+	assert.Error(t, err)
+}
@@ -0,0 +1,196 @@
+package keymanager
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dcoker/biscuit/internal/shamir"
+)
+
+const (
+	// ShamirLabel is the label for the Shamir secret sharing key manager.
+	ShamirLabel = "shamir"
+)
+
+func init() {
+	registry[ShamirLabel] = NewShamir
+}
+
+// Shamir is a KeyManager that splits each data key into shares with Shamir's secret sharing, and
+// gives each share to a custodian: a key under another key manager. Any threshold of the custodians
+// can recover the data key, and fewer cannot. The key ID is the threshold followed by the
+// custodians, separated by "|", each written as LABEL:KEYID:
+//
+//	2|kms:arn:aws:kms:us-east-1:111111111111:key/...|kms:arn:aws:kms:us-west-2:222222222222:key/...|age:age1...
+//
+// The shares, each encrypted with AES-GCM under a data key from its custodian, are stored in the key
+// ciphertext.
+type Shamir struct{}
+
+// NewShamir returns a new Shamir.
+func NewShamir() KeyManager {
+	return &Shamir{}
+}
+
+// custodian is a key that holds one share of the data key.
+type custodian struct {
+	KeyManager string `json:"key_manager"`
+	KeyID      string `json:"key_id"`
+}
+
+// shares is the key ciphertext of a value encrypted under Shamir.
+type shares struct {
+	Threshold int     `json:"threshold"`
+	Shares    []share `json:"shares"`
+}
+
+// share is one share of the data key, encrypted under a data key from its custodian.
+type share struct {
+	custodian
+	KeyCiphertext []byte `json:"key_ciphertext"`
+	Nonce         []byte `json:"nonce"`
+	Ciphertext    []byte `json:"ciphertext"`
+}
+
+// parseShamirKeyID returns the threshold and custodians named by keyID.
+func parseShamirKeyID(keyID string) (int, []custodian, error) {
+	fields := strings.Split(keyID, "|")
+	threshold, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, nil, fmt.Errorf("Invalid shamir key ID %q: it must start with the number of custodians "+
+			"needed, as in 2|kms:KEY|kms:KEY|kms:KEY.", keyID)
+	}
+	var custodians []custodian
+	for _, field := range fields[1:] {
+		label, id := SplitLabel(field, "")
+		if label == "" || label == ShamirLabel {
+			return 0, nil, fmt.Errorf("Invalid shamir custodian %q: expected LABEL:KEYID, where LABEL is a "+
+				"key manager other than %s.", field, ShamirLabel)
+		}
+		custodians = append(custodians, custodian{KeyManager: label, KeyID: id})
+	}
+	if threshold < 2 || threshold > len(custodians) || len(custodians) > 255 {
+		return 0, nil, fmt.Errorf("Invalid shamir key ID %q: the number of custodians needed must be "+
+			"at least 2 and at most the number of custodians, which must be at most 255.", keyID)
+	}
+	return threshold, custodians, nil
+}
+
+// GenerateEnvelopeKey generates a random data key, splits it, and encrypts a share for each
+// custodian. Every custodian must be available. The resolved key ID is keyID itself, so that it
+// matches the template; each share records the key ID its custodian resolved to.
+func (s *Shamir) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	threshold, custodians, err := parseShamirKeyID(keyID)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return EnvelopeKey{}, err
+	}
+	split, err := shamir.Split(dataKey, len(custodians), threshold)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+
+	layout := shares{Threshold: threshold}
+	for i, c := range custodians {
+		manager, err := New(c.KeyManager)
+		if err != nil {
+			return EnvelopeKey{}, err
+		}
+		shareKey, err := manager.GenerateEnvelopeKey(ctx, c.KeyID, secretID)
+		if err != nil {
+			return EnvelopeKey{}, fmt.Errorf("%s:%s: %s", c.KeyManager, c.KeyID, err)
+		}
+		aead, err := shareAEAD(shareKey.Plaintext)
+		if err != nil {
+			return EnvelopeKey{}, err
+		}
+		encrypted := share{
+			custodian:     custodian{KeyManager: c.KeyManager, KeyID: shareKey.ResolvedID},
+			KeyCiphertext: shareKey.Ciphertext,
+			Nonce:         make([]byte, aead.NonceSize()),
+		}
+		if _, err := rand.Read(encrypted.Nonce); err != nil {
+			return EnvelopeKey{}, err
+		}
+		encrypted.Ciphertext = aead.Seal(nil, encrypted.Nonce, split[i], []byte(secretID))
+		layout.Shares = append(layout.Shares, encrypted)
+	}
+	ciphertext, err := json.Marshal(layout)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{
+		ResolvedID: keyID,
+		Plaintext:  dataKey,
+		Ciphertext: ciphertext}, nil
+}
+
+// Decrypt decrypts shares until it has enough to recover the data key.
+func (s *Shamir) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	var layout shares
+	if err := json.Unmarshal(keyCiphertext, &layout); err != nil {
+		return nil, fmt.Errorf("key_ciphertext is corrupted: %s", err)
+	}
+	var decrypted [][]byte
+	var failures []string
+	for _, encrypted := range layout.Shares {
+		if len(decrypted) == layout.Threshold {
+			break
+		}
+		plaintext, err := decryptShare(ctx, encrypted, secretID)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s:%s: %s", encrypted.KeyManager, encrypted.KeyID, err))
+			continue
+		}
+		decrypted = append(decrypted, plaintext)
+	}
+	if layout.Threshold < 2 || len(decrypted) < layout.Threshold {
+		return nil, fmt.Errorf("%d of the %d shares needed could be decrypted: %s", len(decrypted),
+			layout.Threshold, strings.Join(failures, "; "))
+	}
+	return shamir.Combine(decrypted)
+}
+
+// Label returns ShamirLabel.
+func (s *Shamir) Label() string {
+	return ShamirLabel
+}
+
+func decryptShare(ctx context.Context, encrypted share, secretID string) ([]byte, error) {
+	if encrypted.KeyManager == ShamirLabel {
+		return nil, fmt.Errorf("a %s custodian cannot itself be %s", ShamirLabel, ShamirLabel)
+	}
+	manager, err := New(encrypted.KeyManager)
+	if err != nil {
+		return nil, err
+	}
+	shareKey, err := manager.Decrypt(ctx, encrypted.KeyID, encrypted.KeyCiphertext, secretID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := shareAEAD(shareKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("the share is corrupted")
+	}
+	return aead.Open(nil, encrypted.Nonce, encrypted.Ciphertext, []byte(secretID))
+}
+
+func shareAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,102 @@
+package keymanager
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCustodian is a key manager whose "encryption" is the identity, and which fails for keys in
+// unavailable.
+type fakeCustodian struct {
+	unavailable map[string]bool
+}
+
+func (f *fakeCustodian) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	if f.unavailable[keyID] {
+		return EnvelopeKey{}, errors.New("unavailable")
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return EnvelopeKey{}, err
+	}
+	return EnvelopeKey{ResolvedID: "resolved-" + keyID, Plaintext: dataKey, Ciphertext: dataKey}, nil
+}
+
+func (f *fakeCustodian) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	if f.unavailable[keyID] {
+		return nil, errors.New("unavailable")
+	}
+	return keyCiphertext, nil
+}
+
+func (f *fakeCustodian) Label() string {
+	return "fake"
+}
+
+func useFakeCustodian(t *testing.T) *fakeCustodian {
+	custodian := &fakeCustodian{unavailable: map[string]bool{}}
+	registry["fake"] = func() KeyManager { return custodian }
+	t.Cleanup(func() { delete(registry, "fake") })
+	return custodian
+}
+
+func TestShamir(t *testing.T) {
+	ctx := context.Background()
+	custodian := useFakeCustodian(t)
+	manager, err := New(ShamirLabel)
+	assert.NoError(t, err)
+
+	key, err := manager.GenerateEnvelopeKey(ctx, "2|fake:a|fake:b|fake:c", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "2|fake:a|fake:b|fake:c", key.ResolvedID)
+	assert.Len(t, key.Plaintext, 32)
+
+	plaintext, err := manager.Decrypt(ctx, key.ResolvedID, key.Ciphertext, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, key.Plaintext, plaintext)
+
+	// Any two of the three custodians are enough.
+	custodian.unavailable["resolved-a"] = true
+	plaintext, err = manager.Decrypt(ctx, key.ResolvedID, key.Ciphertext, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, key.Plaintext, plaintext)
+
+	// One is not.
+	custodian.unavailable["resolved-c"] = true
+	_, err = manager.Decrypt(ctx, key.ResolvedID, key.Ciphertext, "password")
+	assert.EqualError(t, err, "1 of the 2 shares needed could be decrypted: "+
+		"fake:resolved-a: unavailable; fake:resolved-c: unavailable")
+
+	// The shares are bound to the name of the secret.
+	delete(custodian.unavailable, "resolved-a")
+	_, err = manager.Decrypt(ctx, key.ResolvedID, key.Ciphertext, "username")
+	assert.Error(t, err)
+
+	// Every custodian must be available to encrypt.
+	_, err = manager.GenerateEnvelopeKey(ctx, "2|fake:a|fake:b|fake:resolved-c", "password")
+	assert.EqualError(t, err, "fake:resolved-c: unavailable")
+}
+
+func TestShamirKeyID(t *testing.T) {
+	useFakeCustodian(t)
+	threshold, custodians, err := parseShamirKeyID("2|fake:a|fake:b:c|fake:d;e")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, threshold)
+	assert.Equal(t, []custodian{{"fake", "a"}, {"fake", "b:c"}, {"fake", "d;e"}}, custodians)
+
+	for _, keyID := range []string{
+		"",
+		"fake:a|fake:b",
+		"1|fake:a|fake:b",
+		"3|fake:a|fake:b",
+		"2|fake:a|b",
+		"2|fake:a|shamir:2|fake:b|fake:c",
+	} {
+		_, _, err := parseShamirKeyID(keyID)
+		assert.Error(t, err, keyID)
+	}
+}
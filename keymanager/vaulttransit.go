@@ -0,0 +1,141 @@
+package keymanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// VaultTransitLabel is the label for the HashiCorp Vault Transit key manager.
+	VaultTransitLabel = "vault-transit"
+
+	defaultTransitMount = "transit"
+)
+
+func init() {
+	registry[VaultTransitLabel] = NewVaultTransit
+}
+
+var errVaultNotConfigured = errors.New("Please set VAULT_ADDR and VAULT_TOKEN to use the " +
+	VaultTransitLabel + " key manager.")
+
+// VaultTransit is a KeyManager for the Transit secrets engine of HashiCorp Vault. The key ID is the
+// name of a Transit key, optionally preceded by the path the engine is mounted at, as in
+// "biscuit" or "secrets/transit/biscuit". The mount defaults to "transit". Vault is found with the
+// VAULT_ADDR, VAULT_TOKEN and, optionally, VAULT_NAMESPACE environment variables.
+//
+// The name of the secret is passed as the Transit context, so that a key created with derived=true
+// derives a different key for each secret. Vault ignores the context for other keys.
+type VaultTransit struct {
+	client *http.Client
+}
+
+// NewVaultTransit returns a new VaultTransit.
+func NewVaultTransit() KeyManager {
+	return &VaultTransit{client: http.DefaultClient}
+}
+
+// GenerateEnvelopeKey asks Vault for a new data key, encrypted under the Transit key keyID.
+func (v *VaultTransit) GenerateEnvelopeKey(ctx context.Context, keyID string, secretID string) (EnvelopeKey, error) {
+	var response struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := v.call(ctx, keyID, "datakey/plaintext", map[string]interface{}{
+		"bits":    256,
+		"context": base64.StdEncoding.EncodeToString([]byte(secretID)),
+	}, &response)
+	if err != nil {
+		return EnvelopeKey{}, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(response.Plaintext)
+	if err != nil {
+		return EnvelopeKey{}, fmt.Errorf("%s: Vault returned an invalid data key: %s", keyID, err)
+	}
+	return EnvelopeKey{
+		ResolvedID: keyID,
+		Plaintext:  plaintext,
+		Ciphertext: []byte(response.Ciphertext)}, nil
+}
+
+// Decrypt asks Vault to decrypt the data key under the Transit key keyID.
+func (v *VaultTransit) Decrypt(ctx context.Context, keyID string, keyCiphertext []byte, secretID string) ([]byte, error) {
+	var response struct {
+		Plaintext string `json:"plaintext"`
+	}
+	err := v.call(ctx, keyID, "decrypt", map[string]interface{}{
+		"ciphertext": string(keyCiphertext),
+		"context":    base64.StdEncoding.EncodeToString([]byte(secretID)),
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(response.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Vault returned an invalid data key: %s", keyID, err)
+	}
+	return plaintext, nil
+}
+
+// Label returns VaultTransitLabel.
+func (v *VaultTransit) Label() string {
+	return VaultTransitLabel
+}
+
+// call POSTs request to the Transit endpoint operation for the key keyID, and decodes the data in
+// the response into response.
+func (v *VaultTransit) call(ctx context.Context, keyID, operation string, request, response interface{}) error {
+	address, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		return errVaultNotConfigured
+	}
+	mount, key := defaultTransitMount, strings.Trim(keyID, "/")
+	if i := strings.LastIndex(key, "/"); i >= 0 {
+		mount, key = key[:i], key[i+1:]
+	}
+	if key == "" {
+		return errors.New("Please specify the name of a Transit key as the key ID.")
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimRight(address, "/") + "/v1/" + mount + "/" + operation + "/" + key
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var decoded struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("%s: unexpected response from Vault (%s): %s", keyID, resp.Status, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		if len(decoded.Errors) == 0 {
+			return fmt.Errorf("%s: Vault returned %s", keyID, resp.Status)
+		}
+		return fmt.Errorf("%s: Vault returned %s: %s", keyID, resp.Status, strings.Join(decoded.Errors, "; "))
+	}
+	return json.Unmarshal(decoded.Data, response)
+}
@@ -0,0 +1,126 @@
+package keymanager
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTransit stands in for the Transit secrets engine. Its "encryption" prefixes the data key with
+// the key name and the context, so that decryption fails if either differs.
+type fakeTransit struct {
+	t     *testing.T
+	paths []string
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.paths = append(f.paths, r.URL.Path)
+	if r.Method != http.MethodPost || r.Header.Get("X-Vault-Token") != "s.token" {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	var request struct {
+		Bits       int    `json:"bits"`
+		Context    string `json:"context"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	assert.NoError(f.t, json.NewDecoder(r.Body).Decode(&request))
+	parts := strings.Split(r.URL.Path, "/")
+	key := parts[len(parts)-1]
+	prefix := "vault:v1:" + key + ":" + request.Context + ":"
+
+	var data interface{}
+	switch {
+	case strings.Contains(r.URL.Path, "/datakey/plaintext/"):
+		assert.Equal(f.t, 256, request.Bits)
+		plaintext := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+		data = map[string]string{"plaintext": plaintext, "ciphertext": prefix + plaintext}
+	case strings.Contains(r.URL.Path, "/decrypt/"):
+		if !strings.HasPrefix(request.Ciphertext, prefix) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["cipher: message authentication failed"]}`))
+			return
+		}
+		data = map[string]string{"plaintext": strings.TrimPrefix(request.Ciphertext, prefix)}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// useVault points the Vault Transit key manager at a server.
+func useVault(t *testing.T, address, token string) {
+	for name, value := range map[string]string{"VAULT_ADDR": address, "VAULT_TOKEN": token} {
+		previous, set := os.LookupEnv(name)
+		assert.NoError(t, os.Setenv(name, value))
+		name := name
+		t.Cleanup(func() {
+			if set {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+}
+
+func TestVaultTransit(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeTransit{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	useVault(t, server.URL, "s.token")
+
+	manager, err := New(VaultTransitLabel)
+	assert.NoError(t, err)
+	key, err := manager.GenerateEnvelopeKey(ctx, "biscuit", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, "biscuit", key.ResolvedID)
+	assert.Equal(t, []byte(strings.Repeat("k", 32)), key.Plaintext)
+	assert.True(t, strings.HasPrefix(string(key.Ciphertext), "vault:v1:biscuit:cGFzc3dvcmQ=:"))
+
+	plaintext, err := manager.Decrypt(ctx, "biscuit", key.Ciphertext, "password")
+	assert.NoError(t, err)
+	assert.Equal(t, key.Plaintext, plaintext)
+
+	// The context binds the data key to the name of the secret.
+	_, err = manager.Decrypt(ctx, "biscuit", key.Ciphertext, "username")
+	assert.EqualError(t, err, "biscuit: Vault returned 400 Bad Request: cipher: message authentication failed")
+
+	_, err = manager.GenerateEnvelopeKey(ctx, "secrets/transit/biscuit", "password")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"/v1/transit/datakey/plaintext/biscuit",
+		"/v1/transit/decrypt/biscuit",
+		"/v1/transit/decrypt/biscuit",
+		"/v1/secrets/transit/datakey/plaintext/biscuit",
+	}, fake.paths)
+}
+
+func TestVaultTransit_errors(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(&fakeTransit{t: t})
+	defer server.Close()
+
+	useVault(t, "", "")
+	_, err := NewVaultTransit().GenerateEnvelopeKey(ctx, "biscuit", "password")
+	assert.Equal(t, errVaultNotConfigured, err)
+
+	useVault(t, server.URL, "s.wrong")
+	_, err = NewVaultTransit().GenerateEnvelopeKey(ctx, "biscuit", "password")
+	assert.EqualError(t, err, "biscuit: Vault returned 403 Forbidden: permission denied")
+
+	useVault(t, server.URL, "s.token")
+	_, err = NewVaultTransit().GenerateEnvelopeKey(ctx, "", "password")
+	assert.Error(t, err)
+}
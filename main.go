@@ -15,8 +15,13 @@ import (
 	"github.com/dcoker/biscuit/algorithms/aesgcm256"
 	"github.com/dcoker/biscuit/algorithms/plain"
 	"github.com/dcoker/biscuit/algorithms/secretbox"
+	"github.com/dcoker/biscuit/algorithms/xchacha20poly1305"
 	"github.com/dcoker/biscuit/cmd"
 	"github.com/dcoker/biscuit/cmd/awskms"
+	"github.com/dcoker/biscuit/store"
+	"github.com/dcoker/biscuit/store/dynamodbstore"
+	"github.com/dcoker/biscuit/store/s3store"
+	"github.com/dcoker/biscuit/store/ssmstore"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
@@ -37,6 +42,25 @@ func registerAlgorithms() error {
 	if err := algorithms.Register(aesgcm256.Name, aesgcm256.New()); err != nil {
 		return err
 	}
+	if err := algorithms.Register(aesgcm256.V2Name, aesgcm256.NewV2()); err != nil {
+		return err
+	}
+	if err := algorithms.Register(xchacha20poly1305.Name, xchacha20poly1305.New()); err != nil {
+		return err
+	}
+	return nil
+}
+
+func registerStores() error {
+	if err := store.Register(s3store.Scheme, s3store.Open); err != nil {
+		return err
+	}
+	if err := store.Register(ssmstore.Scheme, ssmstore.Open); err != nil {
+		return err
+	}
+	if err := store.Register(dynamodbstore.Scheme, dynamodbstore.Open); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -45,13 +69,29 @@ func main() {
 	if err := registerAlgorithms(); err != nil {
 		log.Fatal(err)
 	}
+	if err := registerStores(); err != nil {
+		log.Fatal(err)
+	}
 	app := kingpin.New("biscuit", mustAsset("data/usage.txt"))
 	app.Version(Version)
 	app.UsageTemplate(kingpin.LongHelpTemplate)
 	getFlags := app.Command("get", "Read a secret.")
 	putFlags := app.Command("put", "Write a secret.")
+	editFlags := app.Command("edit", "Edit a secret with the editor in VISUAL or EDITOR.")
 	listFlags := app.Command("list", "List secrets.")
-	exportFlags := app.Command("export", "Print all secrets to stdout in plaintext YAML.")
+	deleteFlags := app.Command("delete", "Delete secrets.")
+	historyFlags := app.Command("history", "List the versions of a secret.")
+	rollbackFlags := app.Command("rollback", "Restore a previous version of a secret.")
+	verifyFlags := app.Command("verify", "Check that every copy of every secret can be decrypted "+
+		"and that the copies agree.")
+	checkExpiryFlags := app.Command("check-expiry", "List secrets that have expired or are due for "+
+		"rotation, and fail if there are any.")
+	rotateFlags := app.Command("rotate", "Re-encrypt secrets under the keys and algorithm in the "+
+		"template.")
+	exportFlags := app.Command("export", "Print secrets in plaintext as YAML, JSON, dotenv, shell or properties.")
+	execFlags := app.Command("exec", "Run a program with secrets in its environment.")
+	importFlags := app.Command("import", "Encrypt and store every secret in a plaintext dotenv, JSON "+
+		"or YAML file.")
 	kmsFlags := app.Command("kms", "AWS KMS-specific operations.")
 	kmsIDFlags := kmsFlags.Command("get-caller-identity", "Print the AWS credentials.")
 	kmsInitFlags := kmsFlags.Command("init", mustAsset("data/kmsinit.txt"))
@@ -64,8 +104,17 @@ func main() {
 
 	getCommand := cmd.NewGet(getFlags)
 	writeCommand := cmd.NewPut(putFlags)
+	editCommand := cmd.NewEdit(editFlags)
 	listCommand := cmd.NewList(listFlags)
+	deleteCommand := cmd.NewDelete(deleteFlags)
+	historyCommand := cmd.NewHistory(historyFlags)
+	rollbackCommand := cmd.NewRollback(rollbackFlags)
+	verifyCommand := cmd.NewVerify(verifyFlags)
+	checkExpiryCommand := cmd.NewCheckExpiry(checkExpiryFlags)
+	rotateCommand := cmd.NewRotate(rotateFlags)
 	exportCommand := cmd.NewExport(exportFlags)
+	execCommand := cmd.NewExec(execFlags)
+	importCommand := cmd.NewImport(importFlags)
 	kmsIDCommand := awskms.KmsGetCallerIdentity{}
 	kmsEditKeyPolicy := awskms.NewKmsEditKeyPolicy(kmsEditKeyPolicyFlags)
 	kmsGrantsListCommand := awskms.NewKmsGrantsList(kmsGrantsListFlags)
@@ -82,8 +131,22 @@ func main() {
 		err = getCommand.Run(ctx)
 	case putFlags.FullCommand():
 		err = writeCommand.Run(ctx)
+	case editFlags.FullCommand():
+		err = editCommand.Run(ctx)
 	case listFlags.FullCommand():
 		err = listCommand.Run(ctx)
+	case deleteFlags.FullCommand():
+		err = deleteCommand.Run(ctx)
+	case historyFlags.FullCommand():
+		err = historyCommand.Run(ctx)
+	case rollbackFlags.FullCommand():
+		err = rollbackCommand.Run(ctx)
+	case verifyFlags.FullCommand():
+		err = verifyCommand.Run(ctx)
+	case checkExpiryFlags.FullCommand():
+		err = checkExpiryCommand.Run(ctx)
+	case rotateFlags.FullCommand():
+		err = rotateCommand.Run(ctx)
 	case kmsIDFlags.FullCommand():
 		err = kmsIDCommand.Run(ctx)
 	case kmsInitFlags.FullCommand():
@@ -100,6 +163,10 @@ func main() {
 		err = kmsGrantsRetireCommand.Run(ctx)
 	case exportFlags.FullCommand():
 		err = exportCommand.Run(ctx)
+	case execFlags.FullCommand():
+		err = execCommand.Run(ctx)
+	case importFlags.FullCommand():
+		err = importCommand.Run(ctx)
 	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
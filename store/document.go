@@ -0,0 +1,138 @@
+package store
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// Document is an EntryMap encoded as a single YAML object, such as a file in an S3 bucket.
+type Document interface {
+	// Read returns the contents of the document and an opaque revision identifier. If the document
+	// does not exist, Read returns an error wrapping fs.ErrNotExist.
+	Read() (contents []byte, revision string, err error)
+	// Write replaces the contents of the document, but only if it is still at revision. An empty
+	// revision means that the document must not exist yet. If another writer changed the document
+	// first, Write returns an error wrapping ErrConflict.
+	Write(contents []byte, revision string) error
+}
+
+// DocumentStore is a Store that keeps all of the entries in a Document, along with the previous
+// versions of each entry. Updates are optimistic: rather than waiting for a lock, an Update that
+// races with another writer fails with ErrConflict and leaves the other writer's changes in place.
+type DocumentStore struct {
+	doc           Document
+	historyLength int
+}
+
+// NewDocumentStore constructs a DocumentStore that keeps historyLength previous versions of each
+// entry.
+func NewDocumentStore(doc Document, historyLength int) *DocumentStore {
+	return &DocumentStore{doc: doc, historyLength: historyLength}
+}
+
+// Get a value.
+func (d *DocumentStore) Get(name string) (ValueList, error) {
+	entries, err := d.GetAll()
+	if err != nil {
+		return []Value{}, err
+	}
+	return entries.get(name)
+}
+
+// GetAll returns all of the entries in the document.
+func (d *DocumentStore) GetAll() (EntryMap, error) {
+	entries, _, _, err := d.read()
+	return entries, err
+}
+
+// GetVersion returns a specific version of a value, if it is still in the history.
+func (d *DocumentStore) GetVersion(name string, version int) (ValueList, error) {
+	entries, history, _, err := d.read()
+	if err != nil {
+		return nil, err
+	}
+	return versionOf(entries, history, name, version)
+}
+
+// History returns the versions of a value that are still in the history, oldest first, followed by
+// the current version.
+func (d *DocumentStore) History(name string) ([]ValueList, error) {
+	entries, history, _, err := d.read()
+	if err != nil {
+		return nil, err
+	}
+	return versionsOf(entries, history, name)
+}
+
+// GetKeyIds returns the keys specified by the template entry.
+func (d *DocumentStore) GetKeyIds() ([]Key, error) {
+	entries, err := d.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return entries.KeyIds()
+}
+
+// Put a value.
+func (d *DocumentStore) Put(name string, values ValueList) error {
+	return d.Update(putEntries(EntryMap{name: values}))
+}
+
+// PutAll stores several values with a single write.
+func (d *DocumentStore) PutAll(updates EntryMap) error {
+	return d.Update(putEntries(updates))
+}
+
+// Delete removes one or more values. If any of the names do not exist, Delete returns
+// ErrNameNotFound and the document is left unchanged.
+func (d *DocumentStore) Delete(names ...string) error {
+	return d.Update(deleteEntries(names))
+}
+
+// CompareAndSwap replaces the values of name with updated, but only if they are still equal to
+// previous.
+func (d *DocumentStore) CompareAndSwap(name string, previous, updated ValueList) error {
+	return d.Update(compareAndSwap(name, previous, updated))
+}
+
+// Update reads the document, passes its entries to fn, and writes back the entries as fn left them.
+// Entries that fn changed get a new version, and their previous values are kept in the history. If
+// the document was changed after it was read, Update returns ErrConflict.
+func (d *DocumentStore) Update(fn func(entries EntryMap) error) error {
+	return d.rewrite(func(entries, history EntryMap) error {
+		return updateWithHistory(entries, history, d.historyLength, fn)
+	})
+}
+
+// DropHistory removes the previous versions of one or more values, keeping the current ones.
+func (d *DocumentStore) DropHistory(names ...string) error {
+	return d.rewrite(func(entries, history EntryMap) error {
+		return dropHistory(entries, history, names)
+	})
+}
+
+// rewrite reads the document, passes its entries and their history to fn, and writes back the
+// document as fn left them, unless another writer changed it first.
+func (d *DocumentStore) rewrite(fn func(entries, history EntryMap) error) error {
+	entries, history, revision, err := d.read()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := fn(entries, history); err != nil {
+		return err
+	}
+	output, err := encodeEntries(entries, history)
+	if err != nil {
+		return err
+	}
+	return d.doc.Write(output, revision)
+}
+
+func (d *DocumentStore) read() (EntryMap, EntryMap, string, error) {
+	contents, revision, err := d.doc.Read()
+	if err != nil {
+		return make(EntryMap), make(EntryMap), "", err
+	}
+	entries, history, err := decodeEntries(contents)
+	return entries, history, revision, err
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryDocument is a Document that counts writes to produce revisions.
+type memoryDocument struct {
+	contents []byte
+	writes   int
+}
+
+func (m *memoryDocument) Read() ([]byte, string, error) {
+	if m.writes == 0 {
+		return nil, "", fs.ErrNotExist
+	}
+	return m.contents, fmt.Sprint(m.writes), nil
+}
+
+func (m *memoryDocument) Write(contents []byte, revision string) error {
+	if current := fmt.Sprint(m.writes); revision == "" && m.writes > 0 || revision != "" && revision != current {
+		return ErrConflict
+	}
+	m.contents = contents
+	m.writes++
+	return nil
+}
+
+func TestDocumentStore(t *testing.T) {
+	doc := &memoryDocument{}
+	store := NewDocumentStore(doc, DefaultHistoryLength)
+	_, err := store.GetAll()
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	v1 := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c1"}}
+	v2 := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c2"}}
+	assert.NoError(t, store.Put("k1", v1))
+	assert.NoError(t, store.PutAll(EntryMap{"k2": v2, KeyTemplateName: ValueList{{Key: Key{KeyID: "a"}}}}))
+	actual, err := store.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, v1, actual)
+	keys, err := store.GetKeyIds()
+	assert.NoError(t, err)
+	assert.Equal(t, []Key{{KeyID: "a"}}, keys)
+
+	assert.True(t, errors.Is(store.Delete("k1", "k3"), ErrNameNotFound))
+	assert.NoError(t, store.Delete("k1"))
+	_, err = store.Get("k1")
+	assert.Equal(t, ErrNameNotFound, err)
+	assert.True(t, errors.Is(store.CompareAndSwap("k2", v1, v1), ErrConflict))
+
+	assert.NoError(t, store.Put("k2", v1))
+	versions, err := store.History("k2")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	previous, err := store.GetVersion("k2", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "c2", previous[0].Ciphertext)
+}
+
+func TestDocumentStore_concurrentUpdate(t *testing.T) {
+	doc := &memoryDocument{}
+	store := NewDocumentStore(doc, DefaultHistoryLength)
+	assert.NoError(t, store.Put("k1", ValueList{}))
+
+	// Another writer changes the document while fn is running.
+	err := store.Update(func(entries EntryMap) error {
+		return NewDocumentStore(doc, DefaultHistoryLength).Put("k2", ValueList{})
+	})
+	assert.True(t, errors.Is(err, ErrConflict))
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
@@ -0,0 +1,119 @@
+package dynamodbstore
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/yaml.v2"
+)
+
+// The values attribute is converted through the YAML encoding of a ValueList, so that items have
+// exactly the fields of the YAML file: key_id, key_manager, algorithm, key_ciphertext and so on.
+
+func encodeValues(values store.ValueList) (types.AttributeValue, error) {
+	// The version is recorded by the sort key.
+	unversioned := make(store.ValueList, len(values))
+	for i, value := range values {
+		value.Version = 0
+		unversioned[i] = value
+	}
+	contents, err := yaml.Marshal(unversioned)
+	if err != nil {
+		return nil, err
+	}
+	var generic []interface{}
+	if err := yaml.Unmarshal(contents, &generic); err != nil {
+		return nil, err
+	}
+	return toAttributeValue(generic)
+}
+
+func decodeValues(item map[string]types.AttributeValue) (store.ValueList, error) {
+	attribute, ok := item[valuesAttribute]
+	if !ok {
+		return nil, fmt.Errorf("item is missing the %s attribute", valuesAttribute)
+	}
+	generic, err := fromAttributeValue(attribute)
+	if err != nil {
+		return nil, err
+	}
+	contents, err := yaml.Marshal(generic)
+	if err != nil {
+		return nil, err
+	}
+	var values store.ValueList
+	return values, yaml.Unmarshal(contents, &values)
+}
+
+func toAttributeValue(v interface{}) (types.AttributeValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case string:
+		return &types.AttributeValueMemberS{Value: v}, nil
+	case bool:
+		return &types.AttributeValueMemberBOOL{Value: v}, nil
+	case int, int64, uint64, float64:
+		return &types.AttributeValueMemberN{Value: fmt.Sprint(v)}, nil
+	case []interface{}:
+		list := make([]types.AttributeValue, 0, len(v))
+		for _, element := range v {
+			converted, err := toAttributeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return &types.AttributeValueMemberL{Value: list}, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]types.AttributeValue, len(v))
+		for key, element := range v {
+			converted, err := toAttributeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(key)] = converted
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	}
+	return nil, fmt.Errorf("cannot store %T in DynamoDB", v)
+}
+
+func fromAttributeValue(v types.AttributeValue) (interface{}, error) {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberNULL:
+		return nil, nil
+	case *types.AttributeValueMemberS:
+		return v.Value, nil
+	case *types.AttributeValueMemberBOOL:
+		return v.Value, nil
+	case *types.AttributeValueMemberN:
+		if i, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			return i, nil
+		}
+		return strconv.ParseFloat(v.Value, 64)
+	case *types.AttributeValueMemberL:
+		list := make([]interface{}, 0, len(v.Value))
+		for _, element := range v.Value {
+			converted, err := fromAttributeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, converted)
+		}
+		return list, nil
+	case *types.AttributeValueMemberM:
+		m := make(map[string]interface{}, len(v.Value))
+		for key, element := range v.Value {
+			converted, err := fromAttributeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported DynamoDB attribute type %T", v)
+}
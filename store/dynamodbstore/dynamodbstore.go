@@ -0,0 +1,433 @@
+// Package dynamodbstore keeps every version of each secret as an item in a DynamoDB table.
+package dynamodbstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	myAWS "github.com/dcoker/biscuit/internal/aws"
+	"github.com/dcoker/biscuit/store"
+)
+
+// Scheme is the URL scheme of DynamoDB locations, as in dynamodb://table.
+const Scheme = "dynamodb"
+
+// Attribute names used in the table. The table's partition key is nameAttribute, a string, and its
+// sort key is versionAttribute, a number.
+const (
+	nameAttribute    = "name"
+	versionAttribute = "version"
+	valuesAttribute  = "values"
+)
+
+// Put retries this many times when another writer creates the version it was about to write.
+const putAttempts = 5
+
+// Client is the subset of the DynamoDB API used by Store.
+type Client interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// Store keeps each version of an entry as an item whose partition key is the name of the entry and
+// whose sort key is the version number. The values attribute holds the entry's ValueList, with the
+// same fields as the YAML file. Writing an entry adds an item with the next version number, using a
+// condition that fails if another writer has already created that version. Reads use the highest
+// version of each entry. Deleting an entry removes all of its versions.
+type Store struct {
+	ctx    context.Context
+	client Client
+	table  string
+}
+
+// New constructs a Store for table.
+func New(ctx context.Context, client Client, table string) *Store {
+	return &Store{ctx: ctx, client: client, table: table}
+}
+
+// Open constructs a Store for a location of the form dynamodb://table. The region may be given as a
+// query parameter, as in dynamodb://table?region=us-west-2; otherwise the region is taken from the
+// AWS configuration.
+func Open(ctx context.Context, location *url.URL) (store.Store, error) {
+	if location.Host == "" || (location.Path != "" && location.Path != "/") {
+		return nil, fmt.Errorf("%s: DynamoDB locations must be of the form dynamodb://table", location)
+	}
+	var optFns []func(*config.LoadOptions) error
+	if region := location.Query().Get("region"); region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+	cfg, err := myAWS.NewConfig(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, dynamodb.NewFromConfig(cfg), location.Host), nil
+}
+
+// Get returns the latest version of a value.
+func (s *Store) Get(name string) (store.ValueList, error) {
+	values, _, err := s.latest(name)
+	if errors.Is(err, store.ErrNameNotFound) {
+		return []store.Value{}, store.ErrNameNotFound
+	}
+	return values, err
+}
+
+// GetVersion returns a specific version of a value.
+func (s *Store) GetVersion(name string, version int) (store.ValueList, error) {
+	output, err := s.client.GetItem(s.ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            itemKey(name, version),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if output.Item == nil {
+		return nil, fmt.Errorf("%s version %d: %w", name, version, store.ErrVersionNotFound)
+	}
+	values, _, err := decodeItem(output.Item)
+	return values, err
+}
+
+// History returns every version of a value, oldest first.
+func (s *Store) History(name string) ([]store.ValueList, error) {
+	var versions []store.ValueList
+	err := s.queryVersions(name, "", func(item map[string]types.AttributeValue) error {
+		values, _, err := decodeItem(item)
+		versions = append(versions, values)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, store.ErrNameNotFound
+	}
+	return versions, nil
+}
+
+// GetAll returns the latest version of every entry. If the table is empty, GetAll returns an error
+// wrapping fs.ErrNotExist, just as a FileStore does for a missing file.
+func (s *Store) GetAll() (store.EntryMap, error) {
+	entries, _, err := s.getAll()
+	return entries, err
+}
+
+// GetKeyIds returns the keys specified by the template entry.
+func (s *Store) GetKeyIds() ([]store.Key, error) {
+	entries := make(store.EntryMap)
+	template, _, err := s.latest(store.KeyTemplateName)
+	if err == nil {
+		entries[store.KeyTemplateName] = template
+	} else if !errors.Is(err, store.ErrNameNotFound) {
+		return nil, err
+	}
+	return entries.KeyIds()
+}
+
+// Put adds a new version of a value.
+func (s *Store) Put(name string, values store.ValueList) error {
+	var err error
+	for attempt := 0; attempt < putAttempts; attempt++ {
+		var version int
+		_, version, err = s.latest(name)
+		if err != nil && !errors.Is(err, store.ErrNameNotFound) {
+			return err
+		}
+		err = s.put(name, values, version+1)
+		if !errors.Is(err, store.ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+// PutAll adds a new version of several values. Each version is a separate item, so if PutAll fails
+// some of the values may already have been written.
+func (s *Store) PutAll(updates store.EntryMap) error {
+	return s.Update(func(entries store.EntryMap) error {
+		for name, values := range updates {
+			entries[name] = values
+		}
+		return nil
+	})
+}
+
+// Delete removes every version of one or more values. If any of the names do not exist, Delete
+// returns ErrNameNotFound and nothing is removed.
+func (s *Store) Delete(names ...string) error {
+	return s.Update(func(entries store.EntryMap) error {
+		for _, name := range names {
+			if _, present := entries[name]; !present {
+				return fmt.Errorf("%s: %w", name, store.ErrNameNotFound)
+			}
+			delete(entries, name)
+		}
+		return nil
+	})
+}
+
+// CompareAndSwap adds a new version of name with updated, but only if the latest version is still
+// equal to previous.
+func (s *Store) CompareAndSwap(name string, previous, updated store.ValueList) error {
+	current, version, err := s.latest(name)
+	if err != nil && !errors.Is(err, store.ErrNameNotFound) {
+		return err
+	}
+	if (version != 0) != (previous != nil) || !current.Equal(previous) {
+		return fmt.Errorf("%s: %w", name, store.ErrConflict)
+	}
+	return s.put(name, updated, version+1)
+}
+
+// Update reads the latest version of every entry, passes them to fn, and then adds a new version
+// of the entries that fn added or changed and deletes the ones it removed. If another writer has
+// added a version of a changed entry in the meantime, Update returns ErrConflict.
+func (s *Store) Update(fn func(entries store.EntryMap) error) error {
+	entries, versions, err := s.getAll()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	original := make(store.EntryMap, len(entries))
+	for name, values := range entries {
+		original[name] = values
+	}
+	if err := fn(entries); err != nil {
+		return err
+	}
+
+	for _, name := range sortedNames(entries) {
+		if previous, present := original[name]; present && previous.Equal(entries[name]) {
+			continue
+		}
+		if err := s.put(name, entries[name], versions[name]+1); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedNames(original) {
+		if _, present := entries[name]; !present {
+			if err := s.deleteAll(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DropHistory removes every version of one or more values except the latest. If any of the names
+// do not exist, DropHistory returns ErrNameNotFound.
+func (s *Store) DropHistory(names ...string) error {
+	for _, name := range names {
+		_, latest, err := s.latest(name)
+		if err != nil {
+			return err
+		}
+		err = s.queryVersions(name, "#name, #version", func(item map[string]types.AttributeValue) error {
+			version, err := itemVersion(item)
+			if err != nil || version >= latest {
+				return err
+			}
+			_, err = s.client.DeleteItem(s.ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(s.table),
+				Key:       item,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// latest returns the highest version of name.
+func (s *Store) latest(name string) (store.ValueList, int, error) {
+	output, err := s.client.Query(s.ctx, &dynamodb.QueryInput{
+		TableName:                aws.String(s.table),
+		KeyConditionExpression:   aws.String("#name = :name"),
+		ExpressionAttributeNames: map[string]string{"#name": nameAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name": &types.AttributeValueMemberS{Value: name},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(1),
+		ConsistentRead:   aws.Bool(true),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(output.Items) == 0 {
+		return nil, 0, fmt.Errorf("%s: %w", name, store.ErrNameNotFound)
+	}
+	return decodeItem(output.Items[0])
+}
+
+// getAll returns the highest version of every entry, and the version numbers.
+func (s *Store) getAll() (store.EntryMap, map[string]int, error) {
+	entries := make(store.EntryMap)
+	versions := make(map[string]int)
+	input := &dynamodb.ScanInput{
+		TableName:      aws.String(s.table),
+		ConsistentRead: aws.Bool(true),
+	}
+	for {
+		output, err := s.client.Scan(s.ctx, input)
+		if err != nil {
+			return entries, versions, err
+		}
+		for _, item := range output.Items {
+			name, err := stringAttribute(item, nameAttribute)
+			if err != nil {
+				return entries, versions, err
+			}
+			values, version, err := decodeItem(item)
+			if err != nil {
+				return entries, versions, err
+			}
+			if version > versions[name] {
+				entries[name] = values
+				versions[name] = version
+			}
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+	if len(entries) == 0 {
+		return entries, versions, fmt.Errorf("table %s has no entries: %w", s.table, fs.ErrNotExist)
+	}
+	return entries, versions, nil
+}
+
+// put creates an item for a version of name. It fails with ErrConflict if the item already exists.
+func (s *Store) put(name string, values store.ValueList, version int) error {
+	encoded, err := encodeValues(values)
+	if err != nil {
+		return err
+	}
+	item := itemKey(name, version)
+	item[valuesAttribute] = encoded
+	_, err = s.client.PutItem(s.ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(s.table),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#name)"),
+		ExpressionAttributeNames: map[string]string{"#name": nameAttribute},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return fmt.Errorf("%s version %d: %w", name, version, store.ErrConflict)
+	}
+	return err
+}
+
+// deleteAll removes every version of name.
+func (s *Store) deleteAll(name string) error {
+	return s.queryVersions(name, "#name, #version", func(item map[string]types.AttributeValue) error {
+		_, err := s.client.DeleteItem(s.ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(s.table),
+			Key:       item,
+		})
+		return err
+	})
+}
+
+// queryVersions calls fn with every item for name in order of version. If projection is not empty,
+// it lists the attributes to return.
+func (s *Store) queryVersions(name string, projection string, fn func(item map[string]types.AttributeValue) error) error {
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(s.table),
+		KeyConditionExpression:   aws.String("#name = :name"),
+		ExpressionAttributeNames: map[string]string{"#name": nameAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":name": &types.AttributeValueMemberS{Value: name},
+		},
+		ConsistentRead: aws.Bool(true),
+	}
+	if projection != "" {
+		input.ProjectionExpression = aws.String(projection)
+		input.ExpressionAttributeNames["#version"] = versionAttribute
+	}
+	for {
+		output, err := s.client.Query(s.ctx, input)
+		if err != nil {
+			return err
+		}
+		for _, item := range output.Items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		if len(output.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = output.LastEvaluatedKey
+	}
+}
+
+func itemKey(name string, version int) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		nameAttribute:    &types.AttributeValueMemberS{Value: name},
+		versionAttribute: &types.AttributeValueMemberN{Value: strconv.Itoa(version)},
+	}
+}
+
+func itemVersion(item map[string]types.AttributeValue) (int, error) {
+	n, ok := item[versionAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("item is missing the %s attribute", versionAttribute)
+	}
+	version, err := strconv.Atoi(n.Value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s attribute: %w", versionAttribute, err)
+	}
+	return version, nil
+}
+
+func decodeItem(item map[string]types.AttributeValue) (store.ValueList, int, error) {
+	version, err := itemVersion(item)
+	if err != nil {
+		return nil, 0, err
+	}
+	values, err := decodeValues(item)
+	if err != nil {
+		return nil, 0, err
+	}
+	// The version is recorded by the sort key rather than in the values themselves. As in the
+	// YAML file, the first version is left unlabelled.
+	if version > 1 {
+		for i := range values {
+			values[i].Version = version
+		}
+	}
+	return values, version, nil
+}
+
+func stringAttribute(item map[string]types.AttributeValue, name string) (string, error) {
+	s, ok := item[name].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", fmt.Errorf("item is missing the %s attribute", name)
+	}
+	return s.Value, nil
+}
+
+func sortedNames(entries store.EntryMap) []string {
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,200 @@
+package dynamodbstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dcoker/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDynamoDB keeps items in memory and returns Scan results two at a time. It supports only the
+// expressions used by Store.
+type fakeDynamoDB struct {
+	items map[string]map[string]types.AttributeValue
+	// beforePut is called before each PutItem, to simulate concurrent writers.
+	beforePut func()
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: make(map[string]map[string]types.AttributeValue)}
+}
+
+func fakeKey(item map[string]types.AttributeValue) string {
+	name := item[nameAttribute].(*types.AttributeValueMemberS).Value
+	version, _ := strconv.Atoi(item[versionAttribute].(*types.AttributeValueMemberN).Value)
+	return fmt.Sprintf("%s\x00%09d", name, version)
+}
+
+func (f *fakeDynamoDB) sortedKeys() []string {
+	var keys []string
+	for key := range f.items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *fakeDynamoDB) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.items[fakeKey(params.Key)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if f.beforePut != nil {
+		beforePut := f.beforePut
+		f.beforePut = nil
+		beforePut()
+	}
+	key := fakeKey(params.Item)
+	if _, exists := f.items[key]; exists && aws.ToString(params.ConditionExpression) == "attribute_not_exists(#name)" {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	f.items[key] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(f.items, fakeKey(params.Key))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	name := params.ExpressionAttributeValues[":name"].(*types.AttributeValueMemberS).Value
+	output := &dynamodb.QueryOutput{}
+	for _, key := range f.sortedKeys() {
+		if item := f.items[key]; item[nameAttribute].(*types.AttributeValueMemberS).Value == name {
+			output.Items = append(output.Items, item)
+		}
+	}
+	if params.ScanIndexForward != nil && !*params.ScanIndexForward {
+		for i, j := 0, len(output.Items)-1; i < j; i, j = i+1, j-1 {
+			output.Items[i], output.Items[j] = output.Items[j], output.Items[i]
+		}
+	}
+	if params.Limit != nil && len(output.Items) > int(*params.Limit) {
+		output.Items = output.Items[:*params.Limit]
+	}
+	return output, nil
+}
+
+func (f *fakeDynamoDB) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	output := &dynamodb.ScanOutput{}
+	for _, key := range f.sortedKeys() {
+		if params.ExclusiveStartKey != nil && key <= fakeKey(params.ExclusiveStartKey) {
+			continue
+		}
+		if len(output.Items) == 2 {
+			output.LastEvaluatedKey = output.Items[1]
+			break
+		}
+		output.Items = append(output.Items, f.items[key])
+	}
+	return output, nil
+}
+
+func value(ciphertext string) store.ValueList {
+	return store.ValueList{{Key: store.Key{Algorithm: "none"}, Ciphertext: ciphertext}}
+}
+
+func versioned(ciphertext string, version int) store.ValueList {
+	values := value(ciphertext)
+	values[0].Version = version
+	return values
+}
+
+func TestStore(t *testing.T) {
+	fake := newFakeDynamoDB()
+	s := New(context.Background(), fake, "secrets")
+	_, err := s.GetAll()
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	template := store.ValueList{{Key: store.Key{KeyID: "a", KeyManager: "testing", Algorithm: "none"}}}
+	assert.NoError(t, s.Put(store.KeyTemplateName, template))
+	assert.NoError(t, s.Put("k1", value("c1")))
+	assert.NoError(t, s.Put("k1", value("c2")))
+	assert.NoError(t, s.PutAll(store.EntryMap{"k1": value("c3"), "k2": value("c1"), "k3": value("c1")}))
+
+	entries, err := s.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, store.EntryMap{
+		store.KeyTemplateName: template,
+		"k1":                  versioned("c3", 3),
+		"k2":                  value("c1"),
+		"k3":                  value("c1"),
+	}, entries)
+	keys, err := s.GetKeyIds()
+	assert.NoError(t, err)
+	assert.Equal(t, []store.Key{template[0].Key}, keys)
+
+	actual, err := s.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, versioned("c3", 3), actual)
+	history, err := s.History("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []store.ValueList{value("c1"), versioned("c2", 2), versioned("c3", 3)}, history)
+	for version, expected := range history {
+		actual, err := s.GetVersion("k1", version+1)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+	_, err = s.GetVersion("k1", 4)
+	assert.True(t, errors.Is(err, store.ErrVersionNotFound))
+	_, err = s.Get("missing")
+	assert.Equal(t, store.ErrNameNotFound, err)
+
+	// Unchanged entries don't get a new version.
+	assert.NoError(t, s.PutAll(store.EntryMap{"k2": value("c1")}))
+	_, err = s.GetVersion("k2", 2)
+	assert.True(t, errors.Is(err, store.ErrVersionNotFound))
+
+	assert.NoError(t, s.DropHistory("k1"))
+	history, err = s.History("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, []store.ValueList{versioned("c3", 3)}, history)
+	assert.True(t, errors.Is(s.DropHistory("missing"), store.ErrNameNotFound))
+
+	assert.True(t, errors.Is(s.Delete("k1", "missing"), store.ErrNameNotFound))
+	assert.NoError(t, s.Delete("k1", "k3"))
+	assert.Len(t, fake.items, 2)
+}
+
+func TestStore_CompareAndSwap(t *testing.T) {
+	s := New(context.Background(), newFakeDynamoDB(), "secrets")
+	assert.NoError(t, s.CompareAndSwap("k1", nil, value("c1")))
+	assert.True(t, errors.Is(s.CompareAndSwap("k1", nil, value("c2")), store.ErrConflict))
+	assert.NoError(t, s.CompareAndSwap("k1", value("c1"), value("c2")))
+	assert.True(t, errors.Is(s.CompareAndSwap("k1", value("c1"), value("c3")), store.ErrConflict))
+	actual, err := s.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, versioned("c2", 2), actual)
+}
+
+func TestStore_concurrentWriters(t *testing.T) {
+	fake := newFakeDynamoDB()
+	s := New(context.Background(), fake, "secrets")
+	other := New(context.Background(), fake, "secrets")
+	assert.NoError(t, s.Put("k1", value("c1")))
+
+	// Another writer adds version 2 first.
+	fake.beforePut = func() { assert.NoError(t, other.Put("k1", value("other"))) }
+	err := s.Update(func(entries store.EntryMap) error {
+		entries["k1"] = value("c2")
+		return nil
+	})
+	assert.True(t, errors.Is(err, store.ErrConflict))
+
+	// Put retries with the next version instead.
+	fake.beforePut = func() { assert.NoError(t, other.Put("k1", value("other2"))) }
+	assert.NoError(t, s.Put("k1", value("c3")))
+	actual, err := s.GetVersion("k1", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, versioned("c3", 4), actual)
+}
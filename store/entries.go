@@ -0,0 +1,61 @@
+package store
+
+import "fmt"
+
+// get returns the values of name, or ErrNameNotFound.
+func (e EntryMap) get(name string) (ValueList, error) {
+	value, present := e[name]
+	if !present {
+		return []Value{}, ErrNameNotFound
+	}
+	return value, nil
+}
+
+// KeyIds returns the keys specified by the template entry.
+func (e EntryMap) KeyIds() ([]Key, error) {
+	template, present := e[KeyTemplateName]
+	if !present {
+		return nil, ErrNoTemplate
+	}
+
+	var keys []Key
+	for _, entry := range template {
+		keys = append(keys, entry.Key)
+	}
+	return keys, nil
+}
+
+// The functions below return the update functions shared by the Put, PutAll, Delete and
+// CompareAndSwap methods of the Store implementations in this package.
+
+func putEntries(updates EntryMap) func(entries EntryMap) error {
+	return func(entries EntryMap) error {
+		for name, values := range updates {
+			entries[name] = values
+		}
+		return nil
+	}
+}
+
+func deleteEntries(names []string) func(entries EntryMap) error {
+	return func(entries EntryMap) error {
+		for _, name := range names {
+			if _, present := entries[name]; !present {
+				return fmt.Errorf("%s: %w", name, ErrNameNotFound)
+			}
+			delete(entries, name)
+		}
+		return nil
+	}
+}
+
+func compareAndSwap(name string, previous, updated ValueList) func(entries EntryMap) error {
+	return func(entries EntryMap) error {
+		current, present := entries[name]
+		if present != (previous != nil) || !current.Equal(previous) {
+			return fmt.Errorf("%s: %w", name, ErrConflict)
+		}
+		entries[name] = updated
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultHistoryLength is the number of previous versions of each entry that are kept unless the
+// location says otherwise, as in secrets.yml?history=10.
+const DefaultHistoryLength = 5
+
+// Version returns the version of an entry. Entries written before versions were recorded are
+// version 1.
+func (v ValueList) Version() int {
+	if len(v) == 0 || v[0].Version == 0 {
+		return 1
+	}
+	return v[0].Version
+}
+
+// withVersion returns a copy of v in which every Value is labelled with version.
+func (v ValueList) withVersion(version int) ValueList {
+	labelled := make(ValueList, len(v))
+	for i, value := range v {
+		value.Version = version
+		labelled[i] = value
+	}
+	return labelled
+}
+
+// HistoryLength returns the history query parameter of location, or DefaultHistoryLength.
+func HistoryLength(location *url.URL) (int, error) {
+	value := location.Query().Get("history")
+	if value == "" {
+		return DefaultHistoryLength, nil
+	}
+	length, err := strconv.Atoi(value)
+	if err != nil || length < 0 {
+		return 0, fmt.Errorf("%s: history must be a number of versions to keep", location)
+	}
+	return length, nil
+}
+
+// storedValue is a Value as FileStore and DocumentStore write it. The previous versions of an entry
+// are kept in the history of its first Value. Older versions of biscuit ignore fields they do not
+// know, so they see only the current version; if they rewrite the file, the history is lost.
+type storedValue struct {
+	Value   `yaml:",inline"`
+	History ValueList `yaml:"history,omitempty"`
+}
+
+// decodeEntries parses a file or document into its entries and the previous versions of each.
+func decodeEntries(contents []byte) (entries EntryMap, history EntryMap, err error) {
+	entries = make(EntryMap)
+	history = make(EntryMap)
+	stored := make(map[string][]storedValue)
+	if err := yaml.Unmarshal(contents, stored); err != nil {
+		return entries, history, err
+	}
+	for name, values := range stored {
+		entry := make(ValueList, len(values))
+		for i, value := range values {
+			entry[i] = value.Value
+			if len(value.History) > 0 {
+				history[name] = append(history[name], value.History...)
+			}
+		}
+		entries[name] = entry
+	}
+	return entries, history, nil
+}
+
+// encodeEntries is the inverse of decodeEntries.
+func encodeEntries(entries, history EntryMap) ([]byte, error) {
+	stored := make(map[string][]storedValue, len(entries))
+	for name, values := range entries {
+		entry := make([]storedValue, len(values))
+		for i, value := range values {
+			entry[i].Value = value
+		}
+		if len(entry) > 0 {
+			entry[0].History = history[name]
+		}
+		stored[name] = entry
+	}
+	return yaml.Marshal(stored)
+}
+
+// updateWithHistory runs fn on entries and then brings history up to date: every entry that fn
+// changed gets the next version number, and its previous values move into history, keeping at most
+// historyLength previous versions. Entries that fn deleted lose their history too.
+func updateWithHistory(entries, history EntryMap, historyLength int, fn func(entries EntryMap) error) error {
+	before := make(EntryMap, len(entries))
+	for name, values := range entries {
+		before[name] = values
+	}
+	if err := fn(entries); err != nil {
+		return err
+	}
+
+	for name, values := range entries {
+		previous, present := before[name]
+		if present && previous.Equal(values) {
+			continue
+		}
+		if name == KeyTemplateName {
+			continue
+		}
+		version := 1
+		if present {
+			version = previous.Version() + 1
+			history[name] = trimHistory(append(history[name], previous.withVersion(previous.Version())...),
+				historyLength)
+		}
+		if version == 1 {
+			// The first version is left unlabelled, as entries written by older versions of
+			// biscuit are.
+			version = 0
+		}
+		entries[name] = values.withVersion(version)
+	}
+	for name := range history {
+		if _, present := entries[name]; !present {
+			delete(history, name)
+		}
+	}
+	return nil
+}
+
+// dropHistory removes the previous versions of names from history. If any of the names do not
+// exist, it returns ErrNameNotFound.
+func dropHistory(entries, history EntryMap, names []string) error {
+	for _, name := range names {
+		if _, err := entries.get(name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		delete(history, name)
+	}
+	return nil
+}
+
+// trimHistory drops the oldest versions from history until at most historyLength remain.
+func trimHistory(history ValueList, historyLength int) ValueList {
+	versions := groupVersions(history)
+	if len(versions) <= historyLength {
+		return history
+	}
+	var trimmed ValueList
+	for _, values := range versions[len(versions)-historyLength:] {
+		trimmed = append(trimmed, values...)
+	}
+	return trimmed
+}
+
+// groupVersions splits the history of an entry into one ValueList per version, oldest first.
+func groupVersions(history ValueList) []ValueList {
+	var versions []ValueList
+	for _, value := range history {
+		if len(versions) == 0 || versions[len(versions)-1][0].Version != value.Version {
+			versions = append(versions, nil)
+		}
+		versions[len(versions)-1] = append(versions[len(versions)-1], value)
+	}
+	return versions
+}
+
+// versionsOf returns the available versions of name, oldest first, ending with the current one.
+func versionsOf(entries, history EntryMap, name string) ([]ValueList, error) {
+	current, err := entries.get(name)
+	if err != nil {
+		return nil, err
+	}
+	versions := groupVersions(history[name])
+	return append(versions, current.withVersion(current.Version())), nil
+}
+
+// versionOf returns a specific version of name.
+func versionOf(entries, history EntryMap, name string, version int) (ValueList, error) {
+	versions, err := versionsOf(entries, history, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, values := range versions {
+		if values.Version() == version {
+			return values, nil
+		}
+	}
+	return nil, fmt.Errorf("%s version %d: %w", name, version, ErrVersionNotFound)
+}
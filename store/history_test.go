@@ -0,0 +1,102 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestFileStore_History(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	store := NewFileStore(path.Join(dir, "secrets.yml"))
+
+	for i := 1; i <= 8; i++ {
+		values := ValueList{
+			{Key: Key{KeyID: "a", Algorithm: "none"}, Ciphertext: fmt.Sprintf("a%d", i)},
+			{Key: Key{KeyID: "b", Algorithm: "none"}, Ciphertext: fmt.Sprintf("b%d", i)},
+		}
+		assert.NoError(t, store.Put("k1", values))
+	}
+	assert.NoError(t, store.Put("k2", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c"}}))
+
+	// History is not visible to GetAll.
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 8, entries["k1"].Version())
+	assert.Equal(t, 1, entries["k2"].Version())
+
+	versions, err := store.History("k1")
+	assert.NoError(t, err)
+	assert.Len(t, versions, DefaultHistoryLength+1)
+	for i, values := range versions {
+		version := 8 - DefaultHistoryLength + i
+		assert.Equal(t, version, values.Version())
+		assert.Len(t, values, 2)
+		assert.Equal(t, fmt.Sprintf("b%d", version), values[1].Ciphertext)
+	}
+	versions, err = store.History("k2")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	actual, err := store.GetVersion("k1", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "a4", actual[0].Ciphertext)
+	_, err = store.GetVersion("k1", 2)
+	assert.True(t, errors.Is(err, ErrVersionNotFound))
+	_, err = store.GetVersion("missing", 1)
+	assert.True(t, errors.Is(err, ErrNameNotFound))
+
+	// Older versions of biscuit read the file as an EntryMap, and see only the current versions.
+	contents, err := os.ReadFile(store.filename)
+	assert.NoError(t, err)
+	old := make(EntryMap)
+	assert.NoError(t, yaml.Unmarshal(contents, old))
+	assert.Equal(t, entries, old)
+
+	// History is removed along with its entry, or on its own.
+	assert.NoError(t, store.Delete("k1"))
+	_, history, err := store.read()
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+	assert.NoError(t, store.Put("k2", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "d"}}))
+	versions, err = store.History("k2")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.NoError(t, store.DropHistory("k2"))
+	versions, err = store.History("k2")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+	assert.Equal(t, "d", versions[0][0].Ciphertext)
+	assert.Equal(t, 2, versions[0].Version())
+	assert.True(t, errors.Is(store.DropHistory("missing"), ErrNameNotFound))
+}
+
+func TestFileStore_unversionedFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := path.Join(dir, "secrets.yml")
+	assert.NoError(t, os.WriteFile(filename, []byte("k1:\n- algorithm: none\n  ciphertext: old\n"), 0644))
+
+	store := NewFileStoreWithHistory(filename, 1)
+	versions, err := store.History("k1")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+	assert.Equal(t, 1, versions[0].Version())
+
+	assert.NoError(t, store.Put("k1", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "new"}}))
+	assert.NoError(t, store.Put("k1", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "newer"}}))
+	versions, err = store.History("k1")
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "new", versions[0][0].Ciphertext)
+	assert.Equal(t, 3, versions[1].Version())
+}
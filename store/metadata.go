@@ -0,0 +1,119 @@
+package store
+
+import "time"
+
+// Metadata records which version of an entry a Value belongs to, when and by whom that version was
+// written, and what the entry is for. Every Value of an entry carries the same Metadata. Values
+// written by older versions of biscuit have none.
+type Metadata struct {
+	// Version numbers the versions of an entry from 1. It is assigned by the Store.
+	Version int `yaml:"version,omitempty"`
+	// Created is the time at which the first version of the entry was written.
+	Created time.Time `yaml:"created,omitempty"`
+	// Updated is the time at which this version was written.
+	Updated time.Time `yaml:"updated,omitempty"`
+	// Changed is the time at which the plaintext of this version was first written. Unlike Updated,
+	// it is kept when a version only re-encrypts the plaintext of the one before, so it is the time
+	// that rotation is due from. Values written by older versions of biscuit have none.
+	Changed time.Time `yaml:"changed,omitempty"`
+	// Author identifies who wrote this version: the ARN of their AWS identity when the entry is
+	// encrypted under KMS, and user@host otherwise.
+	Author string `yaml:"author,omitempty"`
+	// Description says what the entry is for.
+	Description string `yaml:"description,omitempty"`
+	// Tags are arbitrary labels for the entry.
+	Tags map[string]string `yaml:"tags,omitempty"`
+	// Expires is the time after which the plaintext of the entry should no longer be used.
+	Expires time.Time `yaml:"expires,omitempty"`
+	// RotateEvery is how long a version of the entry may be used before it should be replaced, such
+	// as "90d".
+	RotateEvery string `yaml:"rotate_every,omitempty"`
+}
+
+// Metadata returns the metadata of an entry.
+func (v ValueList) Metadata() Metadata {
+	if len(v) == 0 {
+		return Metadata{}
+	}
+	return v[0].Metadata
+}
+
+// InheritMetadata returns a copy of values, the new version of a secret whose current version is
+// current, with the metadata that describes the secret as a whole rather than a single version.
+// Created is kept from the current version, or is the time of this update for a new secret. The
+// description and rotation period are kept unless changes sets them, and the tags in changes are
+// added to the current tags.
+//
+// If Changed is set in values, they hold a new plaintext, so the expiry of the old plaintext no
+// longer applies: Expires is that of changes. Otherwise values re-encrypt the current plaintext,
+// and Changed and Expires are kept from the current version.
+func InheritMetadata(values, current ValueList, changes Metadata) ValueList {
+	previous := current.Metadata()
+	metadata := values.Metadata()
+	metadata.Created = previous.Created
+	if len(current) == 0 {
+		metadata.Created = metadata.Updated
+	}
+	metadata.Description = previous.Description
+	if changes.Description != "" {
+		metadata.Description = changes.Description
+	}
+	metadata.Tags = nil
+	for _, source := range []map[string]string{previous.Tags, changes.Tags} {
+		for key, value := range source {
+			if metadata.Tags == nil {
+				metadata.Tags = make(map[string]string)
+			}
+			metadata.Tags[key] = value
+		}
+	}
+	metadata.Expires = changes.Expires
+	if metadata.Changed.IsZero() {
+		metadata.Changed = previous.RotatedAt()
+		if metadata.Expires.IsZero() {
+			metadata.Expires = previous.Expires
+		}
+	}
+	metadata.RotateEvery = previous.RotateEvery
+	if changes.RotateEvery != "" {
+		metadata.RotateEvery = changes.RotateEvery
+	}
+
+	inherited := make(ValueList, len(values))
+	for i, value := range values {
+		value.Metadata = metadata
+		inherited[i] = value
+	}
+	return inherited
+}
+
+// RotatedAt returns the time at which the plaintext was last replaced: Changed, or Updated for
+// values written before biscuit recorded Changed.
+func (m Metadata) RotatedAt() time.Time {
+	if !m.Changed.IsZero() {
+		return m.Changed
+	}
+	return m.Updated
+}
+
+// WithoutExpiry returns a copy of values with no expiry or rotation period.
+func (v ValueList) WithoutExpiry() ValueList {
+	cleared := make(ValueList, len(v))
+	for i, value := range v {
+		value.Expires = time.Time{}
+		value.RotateEvery = ""
+		cleared[i] = value
+	}
+	return cleared
+}
+
+// Reencrypted returns a copy of values without Changed, for values that re-encrypt the plaintext
+// of the current version rather than replace it.
+func (v ValueList) Reencrypted() ValueList {
+	reencrypted := make(ValueList, len(v))
+	for i, value := range v {
+		value.Changed = time.Time{}
+		reencrypted[i] = value
+	}
+	return reencrypted
+}
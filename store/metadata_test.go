@@ -0,0 +1,81 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInheritMetadata(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	first := InheritMetadata(ValueList{{Metadata: Metadata{Updated: created, Changed: created}}}, nil, Metadata{
+		Description: "launch codes",
+		Tags:        map[string]string{"env": "prod", "team": "ops"},
+		RotateEvery: "90d",
+	})
+	assert.Equal(t, Metadata{
+		Created:     created,
+		Updated:     created,
+		Changed:     created,
+		Description: "launch codes",
+		Tags:        map[string]string{"env": "prod", "team": "ops"},
+		RotateEvery: "90d",
+	}, first.Metadata())
+
+	second := ValueList{{Metadata: Metadata{Updated: updated, Changed: updated}},
+		{Metadata: Metadata{Updated: updated, Changed: updated}}}
+	inherited := InheritMetadata(second, first, Metadata{
+		Tags:    map[string]string{"env": "staging"},
+		Expires: expires,
+	})
+	expected := Metadata{
+		Created:     created,
+		Updated:     updated,
+		Changed:     updated,
+		Description: "launch codes",
+		Tags:        map[string]string{"env": "staging", "team": "ops"},
+		Expires:     expires,
+		RotateEvery: "90d",
+	}
+	assert.Equal(t, expected, inherited[0].Metadata)
+	assert.Equal(t, expected, inherited[1].Metadata)
+	assert.Equal(t, Metadata{Updated: updated, Changed: updated}, second[0].Metadata, "values must not be modified")
+	assert.Equal(t, map[string]string{"env": "prod", "team": "ops"}, first.Metadata().Tags)
+}
+
+func TestInheritMetadataExpiry(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	current := ValueList{{Metadata: Metadata{Updated: created, Changed: created, Expires: expires, RotateEvery: "90d"}}}
+
+	// A new plaintext does not inherit the expiry of the old one.
+	written := ValueList{{Metadata: Metadata{Updated: updated, Changed: updated}}}
+	put := InheritMetadata(written, current, Metadata{})
+	assert.True(t, put.Metadata().Expires.IsZero())
+	assert.Equal(t, updated, put.Metadata().RotatedAt())
+	assert.Equal(t, "90d", put.Metadata().RotateEvery)
+	later := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	put = InheritMetadata(written, current, Metadata{Expires: later})
+	assert.Equal(t, later, put.Metadata().Expires)
+
+	// Re-encrypting the same plaintext keeps its expiry and the time it was last rotated.
+	rotated := InheritMetadata(written.Reencrypted(), current, Metadata{})
+	assert.Equal(t, expires, rotated.Metadata().Expires)
+	assert.Equal(t, created, rotated.Metadata().RotatedAt())
+	assert.Equal(t, updated, rotated.Metadata().Updated)
+
+	// Values written before Changed was recorded were last rotated when they were written.
+	old := ValueList{{Metadata: Metadata{Updated: created}}}
+	rotated = InheritMetadata(ValueList{{Metadata: Metadata{Updated: updated}}}, old, Metadata{})
+	assert.Equal(t, created, rotated.Metadata().RotatedAt())
+
+	cleared := put.WithoutExpiry()
+	assert.True(t, cleared.Metadata().Expires.IsZero())
+	assert.Empty(t, cleared.Metadata().RotateEvery)
+	assert.Equal(t, later, put.Metadata().Expires, "values must not be modified")
+}
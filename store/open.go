@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Store is implemented by the backends that hold an EntryMap. Implementations return an error
+// wrapping fs.ErrNotExist from GetAll when the underlying file, bucket object or table has not been
+// created yet, and ErrConflict when a conditional write loses to a concurrent writer.
+type Store interface {
+	// Get returns the values of a single entry, or ErrNameNotFound.
+	Get(name string) (ValueList, error)
+	// GetAll returns all of the entries.
+	GetAll() (EntryMap, error)
+	// GetKeyIds returns the keys listed in the template entry.
+	GetKeyIds() ([]Key, error)
+	// Put replaces the values of a single entry.
+	Put(name string, values ValueList) error
+	// PutAll replaces the values of several entries at once.
+	PutAll(updates EntryMap) error
+	// Delete removes entries. If any of the names do not exist, nothing is removed.
+	Delete(names ...string) error
+	// CompareAndSwap replaces the values of an entry only if they are still equal to previous.
+	CompareAndSwap(name string, previous, updated ValueList) error
+	// Update applies fn to the entries as a single read-modify-write.
+	Update(fn func(entries EntryMap) error) error
+}
+
+// Versioned is implemented by Stores that keep earlier versions of each entry. Versions are
+// numbered from 1 in the order they were written.
+type Versioned interface {
+	// GetVersion returns the values of name as of a particular version, or ErrVersionNotFound.
+	GetVersion(name string, version int) (ValueList, error)
+	// History returns the versions of name that are available, oldest first. The last one is the
+	// current version.
+	History(name string) ([]ValueList, error)
+	// DropHistory removes the previous versions of names, keeping the current ones. If any of the
+	// names do not exist, it returns ErrNameNotFound.
+	DropHistory(names ...string) error
+}
+
+// Opener constructs a Store from a location such as s3://bucket/key.
+type Opener func(ctx context.Context, location *url.URL) (Store, error)
+
+var (
+	openers = map[string]Opener{
+		"file": openFile,
+	}
+)
+
+// Register makes a backend available to Open for locations using scheme.
+func Register(scheme string, opener Opener) error {
+	_, ok := openers[scheme]
+	if ok {
+		return fmt.Errorf("store %v already registered", scheme)
+	}
+	openers[scheme] = opener
+	return nil
+}
+
+// Open returns the Store for location. Locations without a scheme, and file:// URLs, are paths to
+// a YAML file on local disk. Other schemes select the backends that have been registered.
+func Open(ctx context.Context, location string) (Store, error) {
+	if !strings.Contains(location, "://") {
+		return NewFileStore(location), nil
+	}
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", location, err)
+	}
+	opener, ok := openers[parsed.Scheme]
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported storage scheme %q; available schemes are %s",
+			location, parsed.Scheme, strings.Join(schemes(), ", "))
+	}
+	return opener(ctx, parsed)
+}
+
+func schemes() []string {
+	var names []string
+	for k := range openers {
+		names = append(names, k+"://")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// openFile handles file:///absolute/path and file://relative/path. The number of previous versions
+// to keep may be given as a query parameter, as in file://secrets.yml?history=10.
+func openFile(ctx context.Context, location *url.URL) (Store, error) {
+	filename := location.Host + location.Path
+	if filename == "" {
+		return nil, fmt.Errorf("%s: missing path", location)
+	}
+	historyLength, err := HistoryLength(location)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileStoreWithHistory(filename, historyLength), nil
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	ctx := context.Background()
+	for location, expected := range map[string]Store{
+		"secrets.yml":                  NewFileStore("secrets.yml"),
+		"/etc/secrets.yml":             NewFileStore("/etc/secrets.yml"),
+		"file:///etc/secrets.yml":      NewFileStore("/etc/secrets.yml"),
+		"file://secrets.yml":           NewFileStore("secrets.yml"),
+		"file://./config/secrets.yml":  NewFileStore("./config/secrets.yml"),
+		"file://config/secrets%20.yml": NewFileStore("config/secrets .yml"),
+	} {
+		actual, err := Open(ctx, location)
+		assert.NoError(t, err, location)
+		assert.Equal(t, expected, actual, location)
+	}
+
+	_, err := Open(ctx, "gopher://bucket/key")
+	assert.Error(t, err)
+	_, err = Open(ctx, "file://")
+	assert.Error(t, err)
+}
+
+func TestRegister(t *testing.T) {
+	opener := func(ctx context.Context, location *url.URL) (Store, error) {
+		return NewFileStore(location.Host), nil
+	}
+	assert.NoError(t, Register("test", opener))
+	defer delete(openers, "test")
+	assert.Error(t, Register("test", opener))
+
+	actual, err := Open(context.Background(), "test://bucket/key")
+	assert.NoError(t, err)
+	assert.Equal(t, NewFileStore("bucket"), actual)
+}
@@ -0,0 +1,127 @@
+// Package s3store keeps the secrets file in an S3 object.
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	myAWS "github.com/dcoker/biscuit/internal/aws"
+	"github.com/dcoker/biscuit/store"
+)
+
+// Scheme is the URL scheme of S3 locations, as in s3://bucket/path/to/secrets.yml.
+const Scheme = "s3"
+
+// Client is the subset of the S3 API used by Object.
+type Client interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// Object is a store.Document kept in an S3 object. Its ETag is the revision, and writes are
+// conditional on it with If-Match, or If-None-Match when the object does not exist yet, so that
+// concurrent writers cannot silently overwrite each other.
+type Object struct {
+	ctx    context.Context
+	client Client
+	bucket string
+	key    string
+}
+
+// New constructs a Store for the object at key in bucket that keeps historyLength previous versions
+// of each entry.
+func New(ctx context.Context, client Client, bucket, key string, historyLength int) *store.DocumentStore {
+	return store.NewDocumentStore(&Object{ctx: ctx, client: client, bucket: bucket, key: key}, historyLength)
+}
+
+// Open constructs a Store for a location of the form s3://bucket/key. The region of the bucket may
+// be given as a query parameter, as in s3://bucket/key?region=us-west-2; otherwise the region is
+// taken from the AWS configuration. The number of previous versions to keep may be given with the
+// history parameter.
+func Open(ctx context.Context, location *url.URL) (store.Store, error) {
+	bucket := location.Host
+	key := strings.TrimPrefix(location.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("%s: S3 locations must be of the form s3://bucket/key", location)
+	}
+	historyLength, err := store.HistoryLength(location)
+	if err != nil {
+		return nil, err
+	}
+	var optFns []func(*config.LoadOptions) error
+	if region := location.Query().Get("region"); region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+	cfg, err := myAWS.NewConfig(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// S3-compatible stand-ins generally don't resolve bucket names as hostnames.
+		o.UsePathStyle = os.Getenv("AWS_ENDPOINT") != ""
+	})
+	return New(ctx, client, bucket, key, historyLength), nil
+}
+
+// Read returns the contents and ETag of the object.
+func (o *Object) Read() ([]byte, string, error) {
+	output, err := o.client.GetObject(o.ctx, &s3.GetObjectInput{
+		Bucket: aws.String(o.bucket),
+		Key:    aws.String(o.key),
+	})
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, "", fmt.Errorf("could not read %s: %w", o, os.ErrNotExist)
+		}
+		return nil, "", fmt.Errorf("could not read %s: %w", o, err)
+	}
+	defer output.Body.Close()
+	contents, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read %s: %w", o, err)
+	}
+	return contents, aws.ToString(output.ETag), nil
+}
+
+// Write replaces the object if its ETag still matches revision.
+func (o *Object) Write(contents []byte, revision string) error {
+	condition := smithyhttp.AddHeaderValue("If-None-Match", "*")
+	if revision != "" {
+		condition = smithyhttp.AddHeaderValue("If-Match", revision)
+	}
+	_, err := o.client.PutObject(o.ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(o.bucket),
+		Key:         aws.String(o.key),
+		Body:        strings.NewReader(string(contents)),
+		ContentType: aws.String("application/x-yaml"),
+	}, func(options *s3.Options) {
+		options.APIOptions = append(options.APIOptions, condition)
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return fmt.Errorf("%s: %w", o, store.ErrConflict)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not write %s: %w", o, err)
+	}
+	return nil
+}
+
+func (o *Object) String() string {
+	return fmt.Sprintf("s3://%s/%s", o.bucket, o.key)
+}
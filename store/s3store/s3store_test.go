@@ -0,0 +1,110 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/dcoker/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 serves a single bucket from memory, honoring If-Match and If-None-Match on PUT.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	etags   map[string]string
+	puts    int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	etag, exists := f.etags[r.URL.Path]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>`)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(f.objects[r.URL.Path])
+	case http.MethodPut:
+		ifMatch := r.Header.Get("If-Match")
+		ifNoneMatch := r.Header.Get("If-None-Match")
+		if (ifMatch != "" && ifMatch != etag) || (ifNoneMatch == "*" && exists) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(w, `<Error><Code>PreconditionFailed</Code><Message>At least one of the pre-conditions you specified did not hold</Message></Error>`)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.puts++
+		f.objects[r.URL.Path] = body
+		f.etags[r.URL.Path] = fmt.Sprintf(`"%d"`, f.puts)
+		w.Header().Set("ETag", f.etags[r.URL.Path])
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestClient(t *testing.T) (*s3.Client, *fakeS3) {
+	fake := &fakeS3{objects: make(map[string][]byte), etags: make(map[string]string)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	client := s3.New(s3.Options{
+		Region:           "us-west-2",
+		EndpointResolver: s3.EndpointResolverFromURL(server.URL),
+		UsePathStyle:     true,
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		}),
+	})
+	return client, fake
+}
+
+func TestObject(t *testing.T) {
+	client, fake := newTestClient(t)
+	s := New(context.Background(), client, "bucket", "path/secrets.yml", store.DefaultHistoryLength)
+	_, err := s.GetAll()
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	v1 := store.ValueList{{Key: store.Key{Algorithm: "none"}, Ciphertext: "c1"}}
+	assert.NoError(t, s.Put("k1", v1))
+	assert.NoError(t, s.Put("k2", v1))
+	entries, err := s.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, store.EntryMap{"k1": v1, "k2": v1}, entries)
+	assert.Contains(t, fake.objects, "/bucket/path/secrets.yml")
+}
+
+func TestObject_conflict(t *testing.T) {
+	client, _ := newTestClient(t)
+	s := New(context.Background(), client, "bucket", "secrets.yml", store.DefaultHistoryLength)
+	other := New(context.Background(), client, "bucket", "secrets.yml", store.DefaultHistoryLength)
+
+	v1 := store.ValueList{{Key: store.Key{Algorithm: "none"}, Ciphertext: "c1"}}
+	// Both writers try to create the object.
+	err := s.Update(func(entries store.EntryMap) error {
+		return other.Put("k1", v1)
+	})
+	assert.True(t, errors.Is(err, store.ErrConflict))
+
+	// Both writers try to update the object.
+	err = s.Update(func(entries store.EntryMap) error {
+		return other.Put("k2", v1)
+	})
+	assert.True(t, errors.Is(err, store.ErrConflict))
+
+	entries, err := s.GetAll()
+	assert.NoError(t, err)
+	assert.Equal(t, store.EntryMap{"k1": v1, "k2": v1}, entries)
+}
@@ -67,7 +67,7 @@ func lessWithRegionOrdering(ordering map[string]int, left Value, right Value) bo
 	if nat > 0 {
 		return false
 	}
-	if left.KeyManager != keymanager.KmsLabel {
+	if !keymanager.IsKms(left.KeyManager) {
 		return false
 	}
 	leftKey, err := arn.New(left.KeyID)
@@ -0,0 +1,288 @@
+// Package ssmstore keeps each secret in its own SSM Parameter Store parameter.
+package ssmstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	myAWS "github.com/dcoker/biscuit/internal/aws"
+	"github.com/dcoker/biscuit/store"
+	"gopkg.in/yaml.v2"
+)
+
+// Scheme is the URL scheme of SSM locations, as in ssm://myapp/production.
+const Scheme = "ssm"
+
+// DeleteParameters accepts at most this many names per call.
+const deleteBatchSize = 10
+
+// anyVersion is passed to put to overwrite a parameter regardless of its current version.
+const anyVersion = -1
+
+// Client is the subset of the SSM API used by Store.
+type Client interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error)
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	DeleteParameters(ctx context.Context, params *ssm.DeleteParametersInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParametersOutput, error)
+}
+
+// Store keeps the ValueList of each entry, encoded as YAML, in a String parameter named
+// PREFIX/NAME. The template lives in PREFIX/_keys. The values are already encrypted by biscuit, so
+// SecureString is not needed.
+//
+// SSM has no conditional writes, so overwriting a parameter is last-writer-wins, and a conflict is
+// only detected after the fact. Creating a parameter fails with ErrConflict if it already exists.
+// Overwriting a parameter fails with ErrConflict if the version SSM assigns shows that another
+// writer changed it after it was read, but by then the write has already replaced the other
+// writer's value, which survives only in the parameter's history. The Store is therefore not safe
+// for concurrent updates of the same secret.
+type Store struct {
+	ctx    context.Context
+	client Client
+	prefix string
+}
+
+// New constructs a Store for the parameters under prefix, such as /myapp/production.
+func New(ctx context.Context, client Client, prefix string) *Store {
+	return &Store{ctx: ctx, client: client, prefix: "/" + strings.Trim(prefix, "/")}
+}
+
+// Open constructs a Store for a location of the form ssm://path/to/prefix. The region may be given
+// as a query parameter, as in ssm://myapp/production?region=us-west-2; otherwise the region is taken
+// from the AWS configuration.
+func Open(ctx context.Context, location *url.URL) (store.Store, error) {
+	prefix := strings.Trim(location.Host+location.Path, "/")
+	if prefix == "" {
+		return nil, fmt.Errorf("%s: SSM locations must be of the form ssm://path/to/prefix", location)
+	}
+	var optFns []func(*config.LoadOptions) error
+	if region := location.Query().Get("region"); region != "" {
+		optFns = append(optFns, config.WithRegion(region))
+	}
+	cfg, err := myAWS.NewConfig(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, ssm.NewFromConfig(cfg), prefix), nil
+}
+
+// Get a value.
+func (s *Store) Get(name string) (store.ValueList, error) {
+	values, _, err := s.get(name)
+	if errors.Is(err, store.ErrNameNotFound) {
+		return []store.Value{}, store.ErrNameNotFound
+	}
+	return values, err
+}
+
+// GetAll returns all of the entries under the prefix. If there are none, GetAll returns an error
+// wrapping fs.ErrNotExist, just as a FileStore does for a missing file.
+func (s *Store) GetAll() (store.EntryMap, error) {
+	entries, _, err := s.getAll()
+	return entries, err
+}
+
+// GetKeyIds returns the keys specified by the template entry.
+func (s *Store) GetKeyIds() ([]store.Key, error) {
+	entries := make(store.EntryMap)
+	template, _, err := s.get(store.KeyTemplateName)
+	if err == nil {
+		entries[store.KeyTemplateName] = template
+	} else if !errors.Is(err, store.ErrNameNotFound) {
+		return nil, err
+	}
+	return entries.KeyIds()
+}
+
+// Put a value.
+func (s *Store) Put(name string, values store.ValueList) error {
+	return s.put(name, values, anyVersion)
+}
+
+// PutAll stores several values. Each value is a separate parameter, so if PutAll fails some of
+// the values may already have been written.
+func (s *Store) PutAll(updates store.EntryMap) error {
+	return s.Update(func(entries store.EntryMap) error {
+		for name, values := range updates {
+			entries[name] = values
+		}
+		return nil
+	})
+}
+
+// Delete removes one or more values. If any of the names do not exist, Delete returns
+// ErrNameNotFound and nothing is removed.
+func (s *Store) Delete(names ...string) error {
+	return s.Update(func(entries store.EntryMap) error {
+		for _, name := range names {
+			if _, present := entries[name]; !present {
+				return fmt.Errorf("%s: %w", name, store.ErrNameNotFound)
+			}
+			delete(entries, name)
+		}
+		return nil
+	})
+}
+
+// CompareAndSwap replaces the values of name with updated, but only if they are still equal to
+// previous.
+func (s *Store) CompareAndSwap(name string, previous, updated store.ValueList) error {
+	current, version, err := s.get(name)
+	if err != nil && !errors.Is(err, store.ErrNameNotFound) {
+		return err
+	}
+	if (version != 0) != (previous != nil) || !current.Equal(previous) {
+		return fmt.Errorf("%s: %w", name, store.ErrConflict)
+	}
+	return s.put(name, updated, version)
+}
+
+// Update reads every entry, passes them to fn, and then writes the entries that fn added or
+// changed and deletes the ones it removed.
+func (s *Store) Update(fn func(entries store.EntryMap) error) error {
+	entries, versions, err := s.getAll()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	original := make(store.EntryMap, len(entries))
+	for name, values := range entries {
+		original[name] = values
+	}
+	if err := fn(entries); err != nil {
+		return err
+	}
+
+	for _, name := range sortedNames(entries) {
+		if previous, present := original[name]; present && previous.Equal(entries[name]) {
+			continue
+		}
+		if err := s.put(name, entries[name], versions[name]); err != nil {
+			return err
+		}
+	}
+	var removed []string
+	for _, name := range sortedNames(original) {
+		if _, present := entries[name]; !present {
+			removed = append(removed, s.parameterName(name))
+		}
+	}
+	for len(removed) > 0 {
+		batch := removed
+		if len(batch) > deleteBatchSize {
+			batch = batch[:deleteBatchSize]
+		}
+		removed = removed[len(batch):]
+		if _, err := s.client.DeleteParameters(s.ctx, &ssm.DeleteParametersInput{Names: batch}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) parameterName(name string) string {
+	return s.prefix + "/" + name
+}
+
+// get returns the values of name and the version of its parameter.
+func (s *Store) get(name string) (store.ValueList, int64, error) {
+	output, err := s.client.GetParameter(s.ctx, &ssm.GetParameterInput{
+		Name: aws.String(s.parameterName(name)),
+	})
+	if err != nil {
+		var notFound *types.ParameterNotFound
+		if errors.As(err, &notFound) {
+			return nil, 0, fmt.Errorf("%s: %w", name, store.ErrNameNotFound)
+		}
+		return nil, 0, err
+	}
+	values, err := decode(output.Parameter)
+	return values, output.Parameter.Version, err
+}
+
+// getAll returns the entries under the prefix and the versions of their parameters.
+func (s *Store) getAll() (store.EntryMap, map[string]int64, error) {
+	entries := make(store.EntryMap)
+	versions := make(map[string]int64)
+	input := &ssm.GetParametersByPathInput{
+		Path:      aws.String(s.prefix),
+		Recursive: true,
+	}
+	for {
+		output, err := s.client.GetParametersByPath(s.ctx, input)
+		if err != nil {
+			return entries, versions, err
+		}
+		for _, parameter := range output.Parameters {
+			name := strings.TrimPrefix(aws.ToString(parameter.Name), s.prefix+"/")
+			values, err := decode(&parameter)
+			if err != nil {
+				return entries, versions, err
+			}
+			entries[name] = values
+			versions[name] = parameter.Version
+		}
+		if output.NextToken == nil {
+			break
+		}
+		input.NextToken = output.NextToken
+	}
+	if len(entries) == 0 {
+		return entries, versions, fmt.Errorf("no parameters found under %s: %w", s.prefix, fs.ErrNotExist)
+	}
+	return entries, versions, nil
+}
+
+// put writes the values of name. If version is zero the parameter must not exist yet; otherwise,
+// unless it is anyVersion, it must be at that version.
+func (s *Store) put(name string, values store.ValueList, version int64) error {
+	contents, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	output, err := s.client.PutParameter(s.ctx, &ssm.PutParameterInput{
+		Name:      aws.String(s.parameterName(name)),
+		Value:     aws.String(string(contents)),
+		Type:      types.ParameterTypeString,
+		Tier:      types.ParameterTierIntelligentTiering,
+		Overwrite: version != 0,
+	})
+	if err != nil {
+		var exists *types.ParameterAlreadyExists
+		if errors.As(err, &exists) {
+			return fmt.Errorf("%s: %w", name, store.ErrConflict)
+		}
+		return err
+	}
+	if version != anyVersion && version != 0 && output.Version != version+1 {
+		return fmt.Errorf("%s: %w; this write replaced the other writer's value, which is version %d "+
+			"of parameter %s", name, store.ErrConflict, output.Version-1, s.parameterName(name))
+	}
+	return nil
+}
+
+func decode(parameter *types.Parameter) (store.ValueList, error) {
+	var values store.ValueList
+	if err := yaml.Unmarshal([]byte(aws.ToString(parameter.Value)), &values); err != nil {
+		return nil, fmt.Errorf("could not decode %s: %w", aws.ToString(parameter.Name), err)
+	}
+	return values, nil
+}
+
+func sortedNames(entries store.EntryMap) []string {
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
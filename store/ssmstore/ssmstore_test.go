@@ -0,0 +1,175 @@
+package ssmstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/dcoker/biscuit/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSSM keeps parameters in memory and returns GetParametersByPath results two at a time.
+type fakeSSM struct {
+	parameters map[string]types.Parameter
+	// beforePut is called before each PutParameter, to simulate concurrent writers.
+	beforePut func()
+}
+
+func newFakeSSM() *fakeSSM {
+	return &fakeSSM{parameters: make(map[string]types.Parameter)}
+}
+
+func (f *fakeSSM) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	parameter, present := f.parameters[aws.ToString(params.Name)]
+	if !present {
+		return nil, &types.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &parameter}, nil
+}
+
+func (f *fakeSSM) GetParametersByPath(ctx context.Context, params *ssm.GetParametersByPathInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	var names []string
+	for name := range f.parameters {
+		if strings.HasPrefix(name, aws.ToString(params.Path)+"/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	start := 0
+	if params.NextToken != nil {
+		fmt.Sscan(*params.NextToken, &start)
+	}
+	output := &ssm.GetParametersByPathOutput{}
+	for i := start; i < len(names) && i < start+2; i++ {
+		output.Parameters = append(output.Parameters, f.parameters[names[i]])
+	}
+	if start+2 < len(names) {
+		output.NextToken = aws.String(fmt.Sprint(start + 2))
+	}
+	return output, nil
+}
+
+func (f *fakeSSM) PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	if f.beforePut != nil {
+		beforePut := f.beforePut
+		f.beforePut = nil
+		beforePut()
+	}
+	name := aws.ToString(params.Name)
+	parameter, present := f.parameters[name]
+	if present && !params.Overwrite {
+		return nil, &types.ParameterAlreadyExists{}
+	}
+	parameter.Name = params.Name
+	parameter.Value = params.Value
+	parameter.Version++
+	f.parameters[name] = parameter
+	return &ssm.PutParameterOutput{Version: parameter.Version}, nil
+}
+
+func (f *fakeSSM) DeleteParameters(ctx context.Context, params *ssm.DeleteParametersInput, optFns ...func(*ssm.Options)) (*ssm.DeleteParametersOutput, error) {
+	if len(params.Names) > deleteBatchSize {
+		return nil, errors.New("too many names")
+	}
+	output := &ssm.DeleteParametersOutput{}
+	for _, name := range params.Names {
+		if _, present := f.parameters[name]; !present {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+			continue
+		}
+		delete(f.parameters, name)
+		output.DeletedParameters = append(output.DeletedParameters, name)
+	}
+	return output, nil
+}
+
+func value(ciphertext string) store.ValueList {
+	return store.ValueList{{Key: store.Key{Algorithm: "none"}, Ciphertext: ciphertext}}
+}
+
+func TestStore(t *testing.T) {
+	fake := newFakeSSM()
+	s := New(context.Background(), fake, "/myapp/production/")
+	_, err := s.GetAll()
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = s.GetKeyIds()
+	assert.Error(t, err)
+
+	template := store.ValueList{{Key: store.Key{KeyID: "a", KeyManager: "testing", Algorithm: "none"}}}
+	assert.NoError(t, s.Put(store.KeyTemplateName, template))
+	assert.NoError(t, s.Put("k1", value("c1")))
+	updates := store.EntryMap{}
+	for i := 2; i <= 12; i++ {
+		updates[fmt.Sprintf("k%d", i)] = value(fmt.Sprintf("c%d", i))
+	}
+	assert.NoError(t, s.PutAll(updates))
+	assert.Contains(t, fake.parameters, "/myapp/production/_keys")
+	assert.Contains(t, fake.parameters, "/myapp/production/k12")
+
+	entries, err := s.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 13)
+	assert.Equal(t, value("c7"), entries["k7"])
+	keys, err := s.GetKeyIds()
+	assert.NoError(t, err)
+	assert.Equal(t, []store.Key{template[0].Key}, keys)
+	actual, err := s.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, value("c1"), actual)
+	_, err = s.Get("missing")
+	assert.Equal(t, store.ErrNameNotFound, err)
+
+	// A missing name fails the whole operation.
+	assert.True(t, errors.Is(s.Delete("k1", "missing"), store.ErrNameNotFound))
+	assert.Contains(t, fake.parameters, "/myapp/production/k1")
+	assert.NoError(t, s.Delete("k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8", "k9", "k10", "k11"))
+	entries, err = s.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestStore_CompareAndSwap(t *testing.T) {
+	s := New(context.Background(), newFakeSSM(), "/myapp")
+	assert.NoError(t, s.CompareAndSwap("k1", nil, value("c1")))
+	assert.True(t, errors.Is(s.CompareAndSwap("k1", nil, value("c2")), store.ErrConflict))
+	assert.NoError(t, s.CompareAndSwap("k1", value("c1"), value("c2")))
+	assert.True(t, errors.Is(s.CompareAndSwap("k1", value("c1"), value("c3")), store.ErrConflict))
+	actual, err := s.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, value("c2"), actual)
+}
+
+func TestStore_concurrentWriters(t *testing.T) {
+	fake := newFakeSSM()
+	s := New(context.Background(), fake, "/myapp")
+	other := New(context.Background(), fake, "/myapp")
+	assert.NoError(t, s.Put("k1", value("c1")))
+
+	// Another writer creates the same name first.
+	fake.beforePut = func() { assert.NoError(t, other.Put("k2", value("other"))) }
+	err := s.Update(func(entries store.EntryMap) error {
+		entries["k2"] = value("c2")
+		return nil
+	})
+	assert.True(t, errors.Is(err, store.ErrConflict))
+
+	// Another writer overwrites the same name first. The conflict is only detected after the write,
+	// so the last writer wins.
+	fake.beforePut = func() { assert.NoError(t, other.Put("k1", value("other"))) }
+	err = s.Update(func(entries store.EntryMap) error {
+		entries["k1"] = value("c2")
+		return nil
+	})
+	assert.True(t, errors.Is(err, store.ErrConflict))
+	actual, err := s.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, value("c2"), actual)
+}
@@ -2,27 +2,39 @@ package store
 
 import (
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io/fs"
 	"os"
-
-	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"reflect"
 )
 
 // KeyTemplateName is the name of the value that configures the default set of key settings.
 const KeyTemplateName = "_keys"
 
 var (
-	errNoTemplateEntry = errors.New("Template not found. Please specify a key ID with --key-id, or add a " +
+	// ErrNoTemplate is returned by GetKeyIds if there is no template entry.
+	ErrNoTemplate = errors.New("Template not found. Please specify a key ID with --key-id, or add a " +
 		KeyTemplateName + " entry.")
 
 	// ErrNameNotFound is returned by Get if the named secret does not exist.
 	ErrNameNotFound = errors.New("name not found")
+
+	// ErrVersionNotFound is returned by GetVersion if the named secret does not have that version.
+	ErrVersionNotFound = errors.New("version not found")
+
+	// ErrConflict is returned by CompareAndSwap if the secret was changed by another writer.
+	ErrConflict = errors.New("modified by another writer")
 )
 
-// FileStore stores an EntryMap in a YAML file on local disk.
-type FileStore string
+// FileStore stores an EntryMap in a YAML file on local disk, along with previous versions of each
+// entry.
+type FileStore struct {
+	filename      string
+	historyLength int
+}
 
 // EntryMap represents the contents of the file.
 type EntryMap map[string]ValueList
@@ -41,71 +53,214 @@ func (v ValueList) FilterByKeyManager(manager string) ValueList {
 	return results
 }
 
-// NewFileStore constructs a FileStore for a specific filename.
-func NewFileStore(filename string) FileStore {
-	return FileStore(filename)
+// Equal reports whether two ValueLists contain the same Values in the same order.
+func (v ValueList) Equal(other ValueList) bool {
+	if len(v) != len(other) {
+		return false
+	}
+	for i := range v {
+		if !reflect.DeepEqual(v[i], other[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewFileStore constructs a FileStore for a specific filename that keeps DefaultHistoryLength
+// previous versions of each entry.
+func NewFileStore(filename string) *FileStore {
+	return NewFileStoreWithHistory(filename, DefaultHistoryLength)
+}
+
+// NewFileStoreWithHistory constructs a FileStore for a specific filename that keeps historyLength
+// previous versions of each entry.
+func NewFileStoreWithHistory(filename string, historyLength int) *FileStore {
+	return &FileStore{filename: filename, historyLength: historyLength}
 }
 
 // Get a value.
-func (f FileStore) Get(name string) (ValueList, error) {
+func (f *FileStore) Get(name string) (ValueList, error) {
 	entries, err := f.GetAll()
 	if err != nil {
 		return []Value{}, err
 	}
-	value, present := entries[name]
-	if !present {
-		return []Value{}, ErrNameNotFound
-	}
-	return value, nil
+	return entries.get(name)
 }
 
 // Put a value.
-func (f FileStore) Put(name string, values ValueList) error {
-	entries, err := f.GetAll()
+func (f *FileStore) Put(name string, values ValueList) error {
+	return f.Update(putEntries(EntryMap{name: values}))
+}
+
+// PutAll stores several values with a single write. Entries with names that are not present in
+// updates are left unchanged.
+func (f *FileStore) PutAll(updates EntryMap) error {
+	return f.Update(putEntries(updates))
+}
+
+// Delete removes one or more values. If any of the names do not exist, Delete returns
+// ErrNameNotFound and the file is left unchanged.
+func (f *FileStore) Delete(names ...string) error {
+	return f.Update(deleteEntries(names))
+}
+
+// CompareAndSwap replaces the values of name with updated, but only if they are still equal to
+// previous. Otherwise it returns ErrConflict and the file is left unchanged. A nil previous means
+// that name must not exist.
+func (f *FileStore) CompareAndSwap(name string, previous, updated ValueList) error {
+	return f.Update(compareAndSwap(name, previous, updated))
+}
+
+// Update reads the file, passes its entries to fn, and writes back the entries as fn left them.
+// Entries that fn changed get a new version, and their previous values are kept in the history.
+// The file does not need to exist. Writers hold an exclusive lock for the duration of Update, so fn
+// sees the results of all earlier updates and no update is lost. If fn returns an error, the file is
+// left unchanged and Update returns that error.
+func (f *FileStore) Update(fn func(entries EntryMap) error) error {
+	return f.rewrite(func(entries, history EntryMap) error {
+		return updateWithHistory(entries, history, f.historyLength, fn)
+	})
+}
+
+// DropHistory removes the previous versions of one or more values, keeping the current ones.
+func (f *FileStore) DropHistory(names ...string) error {
+	return f.rewrite(func(entries, history EntryMap) error {
+		return dropHistory(entries, history, names)
+	})
+}
+
+// rewrite reads the file under an exclusive lock, passes its entries and their history to fn, and
+// writes back the file as fn left them. If fn returns an error, the file is left unchanged.
+func (f *FileStore) rewrite(fn func(entries, history EntryMap) error) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entries, history, err := f.read()
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return err
 	}
-	entries[name] = values
+	if err := fn(entries, history); err != nil {
+		return err
+	}
+	return f.write(entries, history)
+}
+
+// lock takes an exclusive advisory lock on FILE.lock. The lock file is removed when the lock is
+// released, so after acquiring the lock we check that the file we locked is still the one at that
+// path; if it is not, the previous holder removed it while we were waiting and we start over.
+func (f *FileStore) lock() (func(), error) {
+	name := f.filename + ".lock"
+	for {
+		lockFile, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockExclusive(lockFile); err != nil {
+			lockFile.Close()
+			return nil, fmt.Errorf("could not lock %s: %w", name, err)
+		}
+		locked, err := lockFile.Stat()
+		if err != nil {
+			unlock(lockFile)
+			lockFile.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(name); err == nil && os.SameFile(locked, current) {
+			return func() {
+				os.Remove(name)
+				unlock(lockFile)
+				lockFile.Close()
+			}, nil
+		}
+		unlock(lockFile)
+		lockFile.Close()
+	}
+}
 
-	output, err := yaml.Marshal(entries)
+// write replaces the file with entries and their history. The new contents are written to a
+// uniquely named temporary file in the same directory, flushed to disk, and renamed over the
+// original.
+func (f *FileStore) write(entries, history EntryMap) error {
+	output, err := encodeEntries(entries, history)
 	if err != nil {
 		return err
 	}
-
-	// poor attempt at atomic file write
-	tempfile := string(f) + ".tmp"
-	if err := os.WriteFile(tempfile, output, 0644); err != nil {
+	filename := f.filename
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+	dir := filepath.Dir(filename)
+	tempfile, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempfile.Name())
+	if _, err := tempfile.Write(output); err != nil {
+		tempfile.Close()
+		return err
+	}
+	if err := tempfile.Chmod(mode); err != nil {
+		tempfile.Close()
+		return err
+	}
+	if err := tempfile.Sync(); err != nil {
+		tempfile.Close()
+		return err
+	}
+	if err := tempfile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tempfile.Name(), filename); err != nil {
 		return err
 	}
-	return os.Rename(tempfile, string(f))
+	return syncDir(dir)
 }
 
 // GetAll returns all of the entries in the file.
-func (f FileStore) GetAll() (EntryMap, error) {
-	contents, err := os.ReadFile(string(f))
-	entries := make(EntryMap)
+func (f *FileStore) GetAll() (EntryMap, error) {
+	entries, _, err := f.read()
+	return entries, err
+}
+
+// GetVersion returns a specific version of a value, if it is still in the history.
+func (f *FileStore) GetVersion(name string, version int) (ValueList, error) {
+	entries, history, err := f.read()
 	if err != nil {
-		return entries, fmt.Errorf("could not read file %s: %w", f, err)
+		return nil, err
 	}
-	return entries, yaml.Unmarshal(contents, entries)
+	return versionOf(entries, history, name, version)
 }
 
-// GetKeyIds returns the keys specified by the template entry.
-func (f FileStore) GetKeyIds() ([]Key, error) {
-	entries, err := f.GetAll()
+// History returns the versions of a value that are still in the history, oldest first, followed by
+// the current version.
+func (f *FileStore) History(name string) ([]ValueList, error) {
+	entries, history, err := f.read()
 	if err != nil {
 		return nil, err
 	}
-	template, present := entries[KeyTemplateName]
-	if !present {
-		return nil, errNoTemplateEntry
+	return versionsOf(entries, history, name)
+}
+
+// read returns all of the entries in the file, and their history.
+func (f *FileStore) read() (EntryMap, EntryMap, error) {
+	contents, err := os.ReadFile(f.filename)
+	if err != nil {
+		return make(EntryMap), make(EntryMap), fmt.Errorf("could not read file %s: %w", f.filename, err)
 	}
+	return decodeEntries(contents)
+}
 
-	var keys []Key
-	for _, entry := range template {
-		keys = append(keys, entry.Key)
+// GetKeyIds returns the keys specified by the template entry.
+func (f *FileStore) GetKeyIds() ([]Key, error) {
+	entries, err := f.GetAll()
+	if err != nil {
+		return nil, err
 	}
-	return keys, nil
+	return entries.KeyIds()
 }
 
 // Key defines key and crypto settings for a particular value.
@@ -119,10 +274,26 @@ type Key struct {
 	Algorithm string `yaml:"algorithm"`
 }
 
+// AssociatedData returns the data that AEAD algorithms authenticate along with the ciphertext of the
+// secret called name. It binds the ciphertext to the name of the secret and to the key settings, so
+// that a ciphertext cannot be moved to another entry or Value without failing to decrypt.
+func (k Key) AssociatedData(name string) []byte {
+	var ad []byte
+	for _, field := range []string{name, k.KeyManager, k.KeyID, k.Algorithm} {
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(field)))
+		ad = append(ad, length[:]...)
+		ad = append(ad, field...)
+	}
+	return ad
+}
+
 // Value is one entry in the file.
 type Value struct {
 	// Key references the key and cryptographic settings for this Value.
 	Key `yaml:",inline"`
+	// Metadata describes the version of the entry that this Value belongs to.
+	Metadata `yaml:",inline"`
 
 	// KeyCiphertext is the encryption key that Ciphertext is encrypted with, but encrypted with a
 	// key that only the Provider has.
@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"sync"
 	"testing"
 
 	"fmt"
@@ -83,6 +84,119 @@ func TestStore_writingCreatesFile(t *testing.T) {
 	assert.Len(t, contents, 1)
 }
 
+func TestStore_Delete(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := path.Join(dir, "secrets.yml")
+
+	store := NewFileStore(filename)
+	assert.NoError(t, store.Put("k1", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c1"}}))
+	assert.NoError(t, store.Put("k2", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c2"}}))
+	assert.NoError(t, store.Put("k3", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c3"}}))
+
+	assert.NoError(t, store.Delete("k1", "k3"))
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Contains(t, entries, "k2")
+
+	// A missing name fails the whole operation.
+	err = store.Delete("k2", "k1")
+	assert.True(t, errors.Is(err, ErrNameNotFound))
+	_, err = store.Get("k2")
+	assert.NoError(t, err)
+}
+
+func TestStore_Update(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := path.Join(dir, "secrets.yml")
+
+	store := NewFileStore(filename)
+	assert.NoError(t, store.Put("k1", ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c1"}}))
+
+	// An error from fn leaves the file unchanged.
+	errAbort := errors.New("abort")
+	err = store.Update(func(entries EntryMap) error {
+		delete(entries, "k1")
+		entries["k2"] = ValueList{}
+		return errAbort
+	})
+	assert.Equal(t, errAbort, err)
+	entries, err := store.GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Contains(t, entries, "k1")
+
+	// Neither the lock file nor temporary files are left behind.
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "secrets.yml", files[0].Name())
+}
+
+func TestStore_concurrentPuts(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := path.Join(dir, "secrets.yml")
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store := NewFileStore(filename)
+			value := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: fmt.Sprintf("c%d", i)}}
+			assert.NoError(t, store.Put(fmt.Sprintf("k%d", i), value))
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := NewFileStore(filename).GetAll()
+	assert.NoError(t, err)
+	assert.Len(t, entries, writers)
+}
+
+func TestStore_CompareAndSwap(t *testing.T) {
+	dir, err := os.MkdirTemp("", "TestStore")
+	assert.NoError(t, err)
+	defer mustRemoveAll(dir)
+	filename := path.Join(dir, "secrets.yml")
+
+	store := NewFileStore(filename)
+	v1 := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c1"}}
+	v2 := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c2"}}
+	v3 := ValueList{{Key: Key{Algorithm: "none"}, Ciphertext: "c3"}}
+
+	// A nil previous value requires that the name does not exist.
+	assert.NoError(t, store.CompareAndSwap("k1", nil, v1))
+	assert.True(t, errors.Is(store.CompareAndSwap("k1", nil, v2), ErrConflict))
+
+	assert.NoError(t, store.CompareAndSwap("k1", v1, v2))
+	assert.True(t, errors.Is(store.CompareAndSwap("k1", v1, v3), ErrConflict))
+	actual, err := store.Get("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, v2.withVersion(2), actual)
+}
+
+func TestKey_AssociatedData(t *testing.T) {
+	key := Key{KeyID: "key_id", KeyManager: "kms", Algorithm: "aesgcm256-v2"}
+	assert.Equal(t, key.AssociatedData("name"), key.AssociatedData("name"))
+	assert.NotEqual(t, key.AssociatedData("name"), key.AssociatedData("other"))
+
+	otherKey := key
+	otherKey.KeyID = "other_key_id"
+	assert.NotEqual(t, key.AssociatedData("name"), otherKey.AssociatedData("name"))
+
+	// Fields are length-prefixed, so moving bytes between fields changes the result.
+	shifted := Key{KeyID: "ey_id", KeyManager: "kmsk", Algorithm: "aesgcm256-v2"}
+	assert.NotEqual(t, key.AssociatedData("name"), shifted.AssociatedData("name"))
+}
+
 func mustRemove(filename string) {
 	if err := os.Remove(filename); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to delete: %s\n", filename)
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+)
+
+// Differences describes the ways in which a ValueList does not match the keys in a template. An
+// empty result means that there is exactly one Value for every template key, and that each Value
+// uses the algorithm the template asks for.
+//
+// Values are matched to template keys by key manager and key ID, exactly. Values record the key that
+// was used, such as the key ARN that a KMS alias pointed to, so aliases in keys must be resolved
+// first.
+func (v ValueList) Differences(keys []Key) []string {
+	var reasons []string
+	matched := make([]bool, len(v))
+	for _, key := range keys {
+		found := false
+		for i, value := range v {
+			if matched[i] || !sameKey(key, value.Key) {
+				continue
+			}
+			matched[i] = true
+			found = true
+			if value.Algorithm != key.Algorithm {
+				reasons = append(reasons, fmt.Sprintf("%s uses algorithm %s, the template uses %s",
+					describeKey(value.Key), value.Algorithm, key.Algorithm))
+			}
+			break
+		}
+		if !found {
+			reasons = append(reasons, fmt.Sprintf("not encrypted under %s", describeKey(key)))
+		}
+	}
+	for i, value := range v {
+		if !matched[i] {
+			reasons = append(reasons, fmt.Sprintf("%s is not in the template", describeKey(value.Key)))
+		}
+	}
+	return reasons
+}
+
+func sameKey(template, value Key) bool {
+	return template.KeyManager == value.KeyManager && template.KeyID == value.KeyID
+}
+
+func describeKey(k Key) string {
+	if k.KeyManager == "" {
+		return "algorithm " + k.Algorithm
+	}
+	return k.KeyManager + " key " + k.KeyID
+}
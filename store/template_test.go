@@ -0,0 +1,46 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueList_Differences(t *testing.T) {
+	westKey := Key{KeyID: west2.KeyID, KeyManager: "kms", Algorithm: "secretbox"}
+	eastKey := Key{KeyID: east1.KeyID, KeyManager: "kms", Algorithm: "secretbox"}
+	// Another key in the same account and region as west2.
+	westOtherKey := Key{
+		KeyID:      "arn:aws:kms:us-west-2:922329555442:key/5d4f1b1e-0b5c-4a5e-9a39-8f2d0a6c1e7b",
+		KeyManager: "kms",
+		Algorithm:  "secretbox",
+	}
+	plain := Key{Algorithm: "none"}
+
+	westValue := Value{Key: west2.Key}
+	westValue.Algorithm = "secretbox"
+	eastValue := Value{Key: east1.Key}
+	eastValue.Algorithm = "secretbox"
+	eastAesValue := Value{Key: east1.Key}
+	eastAesValue.Algorithm = "aesgcm256"
+
+	tests := []struct {
+		values  ValueList
+		keys    []Key
+		reasons int
+	}{
+		{ValueList{westValue, eastValue}, []Key{westKey, eastKey}, 0},
+		{ValueList{eastValue, westValue}, []Key{westKey, eastKey}, 0},
+		{ValueList{westValue}, []Key{westKey, eastKey}, 1},
+		{ValueList{westValue, eastValue}, []Key{westKey}, 1},
+		{ValueList{westValue, eastAesValue}, []Key{westKey, eastKey}, 1},
+		{ValueList{westValue, eastValue}, []Key{westOtherKey, eastKey}, 2},
+		{ValueList{{Key: plain}}, []Key{plain}, 0},
+		{ValueList{{Key: plain}}, []Key{westKey}, 2},
+		{ValueList{other}, []Key{other.Key}, 0},
+		{ValueList{}, []Key{}, 0},
+	}
+	for i, tc := range tests {
+		assert.Len(t, tc.values.Differences(tc.keys), tc.reasons, "case %d", i)
+	}
+}
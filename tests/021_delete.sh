@@ -0,0 +1,13 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}"
+biscuit put -f store.yaml username oreilly
+biscuit put -f store.yaml spice scary
+biscuit delete -f store.yaml password spice
+[[ "username" == "$(biscuit list -f store.yaml)" ]]
+[[ "oreilly" == "$(biscuit get -f store.yaml username)" ]]
+! biscuit delete -f store.yaml password
+! biscuit delete -f store.yaml _keys
+grep _keys store.yaml
+biscuit delete -f store.yaml --allow-template _keys
+! grep _keys store.yaml
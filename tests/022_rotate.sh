@@ -0,0 +1,21 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}"
+biscuit delete -f store.yaml --allow-template _keys
+biscuit put -f store.yaml username oreilly --key-id "${ARN1}","${ARN2}" -a aesgcm256
+biscuit rotate -f store.yaml --dry-run | grep '^password: would re-encrypt'
+! biscuit rotate -f store.yaml --dry-run | grep username
+biscuit rotate -f store.yaml
+[[ "" == "$(biscuit rotate -f store.yaml --dry-run)" ]]
+
+# password is now also readable under the second region's key.
+cp store.yaml corrupt1.yaml
+sed -i "s@${ARN1_REGION}@xxx@g" corrupt1.yaml
+[[ "god" == "$(biscuit get -f corrupt1.yaml password)" ]]
+
+# Another key in the same region is a different key, while an alias matches the key it points to.
+biscuit put -f switch.yaml password god --key-id "arn:aws:kms:${ARN1_REGION}:${AWS_ACCOUNT}:alias/biscuit-default"
+[[ "" == "$(biscuit rotate -f switch.yaml --dry-run)" ]]
+biscuit delete -f switch.yaml --allow-template _keys
+biscuit put -f switch.yaml username oreilly --key-id "${MRK_ARN}"
+biscuit rotate -f switch.yaml --dry-run | grep '^password: would re-encrypt'
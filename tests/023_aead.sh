@@ -0,0 +1,6 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}","${ARN2}" -a aesgcm256-v2
+biscuit put -f store.yaml username oreilly --key-id "${ARN1}" -a xchacha20poly1305
+[[ "god" == "$(biscuit get -f store.yaml password)" ]]
+[[ "oreilly" == "$(biscuit get -f store.yaml username)" ]]
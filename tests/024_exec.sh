@@ -0,0 +1,9 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}","${ARN2}"
+biscuit put -f store.yaml db-user oreilly
+[[ "god oreilly" == "$(biscuit exec -f store.yaml -- sh -c 'echo $PASSWORD $DB_USER')" ]]
+[[ "god " == "$(biscuit exec -f store.yaml --only password --prefix APP_ -- sh -c 'echo $APP_PASSWORD $APP_DB_USER')" ]]
+set +e
+biscuit exec -f store.yaml -- sh -c 'exit 3'
+[[ 3 == "$?" ]]
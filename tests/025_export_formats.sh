@@ -0,0 +1,15 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}"
+biscuit put -f store.yaml db_user oreilly
+printf 'line 1\nit'"'"'s line 2\n' | biscuit put -f store.yaml motd -i /dev/stdin
+
+biscuit export -f store.yaml --format json | grep '"password": "god"'
+[[ 'DB_USER="oreilly"' == "$(biscuit export -f store.yaml --format dotenv --only 'db_*')" ]]
+eval "$(biscuit export -f store.yaml --format shell)"
+[[ "$(biscuit get -f store.yaml motd)" == "$(echo -n "${MOTD}")" ]]
+[[ "password=god" == "$(biscuit export -f store.yaml --format properties --exclude motd --exclude 'db_*')" ]]
+
+biscuit export -f store.yaml --format dotenv -o secrets.env
+[[ "-rw-------" == "$(stat -c %A secrets.env)" ]]
+grep '^PASSWORD="god"$' secrets.env
@@ -0,0 +1,21 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}","${ARN2}"
+cat > secrets.env <<'ENV'
+# comment
+PASSWORD=newgod
+DB_USER="oreilly"
+MOTD="line 1\nline 2"
+ENV
+biscuit import -f store.yaml --from dotenv secrets.env
+[[ "oreilly" == "$(biscuit get -f store.yaml DB_USER)" ]]
+[[ "$(printf 'line 1\nline 2')" == "$(biscuit get -f store.yaml MOTD)" ]]
+
+echo '{"password": "ignored", "spice": "scary"}' > secrets.json
+! biscuit import -f store.yaml --from json secrets.json
+[[ "" == "$(biscuit get -f store.yaml spice 2>/dev/null)" ]]
+biscuit import -f store.yaml --from json --skip-existing secrets.json
+[[ "god" == "$(biscuit get -f store.yaml password)" ]]
+[[ "scary" == "$(biscuit get -f store.yaml spice)" ]]
+biscuit import -f store.yaml --from json --overwrite secrets.json
+[[ "ignored" == "$(biscuit get -f store.yaml password)" ]]
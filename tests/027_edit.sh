@@ -0,0 +1,34 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}","${ARN2}"
+cat > editor.sh <<'EDITOR'
+#!/bin/bash
+[[ "-rw-------" == "$(stat -c %A "$1")" ]] || exit 1
+sed -i 's/god/dog/' "$1"
+EDITOR
+chmod +x editor.sh
+EDITOR=./editor.sh biscuit edit -f store.yaml password
+[[ "dog" == "$(biscuit get -f store.yaml password)" ]]
+EDITOR=true biscuit edit -f store.yaml password | grep 'No change'
+# An interrupt while the editor is open is left to the editor, and the edits are still saved.
+cat > interrupted.sh <<'EDITOR'
+#!/bin/bash
+kill -INT $PPID
+sleep 1
+sed -i 's/dog/cat/' "$1"
+EDITOR
+chmod +x interrupted.sh
+EDITOR=./interrupted.sh biscuit edit -f store.yaml password
+[[ "cat" == "$(biscuit get -f store.yaml password)" ]]
+# A SIGTERM ends the editor, and nothing is saved.
+cat > terminated.sh <<'EDITOR'
+#!/bin/bash
+echo "$1" > edited-file
+kill -TERM $PPID
+sleep 5
+sed -i 's/cat/cow/' "$1"
+EDITOR
+chmod +x terminated.sh
+! EDITOR=./terminated.sh biscuit edit -f store.yaml password
+[[ ! -e "$(cat edited-file)" ]]
+[[ "cat" == "$(biscuit get -f store.yaml password)" ]]
@@ -0,0 +1,10 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml -a none first value
+for i in $(seq 1 10); do
+  biscuit put -f store.yaml -a none "secret${i}" "value${i}" &
+done
+wait
+[[ "11" == "$(biscuit list -f store.yaml | wc -l)" ]]
+[[ "value7" == "$(biscuit get -f store.yaml secret7)" ]]
+! ls store.yaml.lock .store.yaml.*.tmp
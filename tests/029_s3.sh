@@ -0,0 +1,11 @@
+#!/bin/bash -x
+set -e
+STORE="s3://${BUCKET}/029/secrets.yml?region=${REGION1}"
+biscuit put -f "${STORE}" --key-id "${ARN1}" launch_codes 0000
+biscuit put -f "${STORE}" password god
+[[ "0000" == "$(biscuit get -f "${STORE}" launch_codes)" ]]
+[[ "god" == "$(biscuit get -f "${STORE}" password)" ]]
+biscuit list -f "${STORE}" | grep launch_codes
+biscuit delete -f "${STORE}" launch_codes
+! biscuit get -f "${STORE}" launch_codes
+! biscuit list -f "s3://${BUCKET}/029/missing.yml?region=${REGION1}"
@@ -0,0 +1,12 @@
+#!/bin/bash -x
+set -e
+STORE="ssm://biscuit-tests/030"
+! biscuit list -f "${STORE}"
+biscuit put -f "${STORE}" --key-id "${ARN1}","${ARN2}" launch_codes 0000
+biscuit put -f "${STORE}" password god
+[[ "0000" == "$(biscuit get -f "${STORE}" launch_codes)" ]]
+[[ "god" == "$(biscuit get -f "${STORE}" --aws-region-priority "${ARN2_REGION}" password)" ]]
+[[ "$(echo -e "launch_codes\npassword")" == "$(biscuit list -f "${STORE}")" ]]
+biscuit delete -f "${STORE}" launch_codes password
+! biscuit get -f "${STORE}" password
+biscuit delete -f "${STORE}" --allow-template _keys
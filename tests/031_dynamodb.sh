@@ -0,0 +1,14 @@
+#!/bin/bash -x
+set -e
+STORE="dynamodb://${TABLE}"
+biscuit put -f "${STORE}" --key-id "${ARN1}" password_031 god
+biscuit put -f "${STORE}" password_031 sex
+biscuit put -f "${STORE}" password_031 love
+[[ "love" == "$(biscuit get -f "${STORE}" password_031)" ]]
+[[ "god" == "$(biscuit get -f "${STORE}" --at-version 1 password_031)" ]]
+[[ "sex" == "$(biscuit get -f "${STORE}" --at-version 2 password_031)" ]]
+! biscuit get -f "${STORE}" --at-version 4 password_031
+biscuit list -f "${STORE}" | grep password_031
+biscuit delete -f "${STORE}" password_031
+! biscuit get -f "${STORE}" password_031
+! biscuit get -f store.yaml --at-version 1 password_031
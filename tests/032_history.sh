@@ -0,0 +1,24 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml --key-id "${ARN1}" password god
+biscuit put -f store.yaml password sex
+biscuit put -f store.yaml password love
+[[ "love" == "$(biscuit get -f store.yaml password)" ]]
+[[ "god" == "$(biscuit get -f store.yaml --at-version 1 password)" ]]
+biscuit history -f store.yaml password | grep "3 (current)"
+biscuit rollback -f store.yaml password --to 2
+[[ "sex" == "$(biscuit get -f store.yaml password)" ]]
+biscuit history -f store.yaml password | grep "4 (current)"
+! biscuit rollback -f store.yaml password --to 4
+[[ "password" == "$(biscuit list -f store.yaml)" ]]
+for i in $(seq 1 10); do biscuit put -f store.yaml password "p${i}"; done
+! biscuit get -f store.yaml --at-version 1 password
+biscuit put -f "file://store2.yaml?history=0" --key-id "${ARN1}" password god
+biscuit put -f "file://store2.yaml?history=0" password sex
+! grep history: store2.yaml
+# rotate removes the versions encrypted under the old keys.
+grep history: store.yaml
+biscuit rotate -f store.yaml --all
+! grep history: store.yaml
+biscuit history -f store.yaml password | grep "(current)"
+
@@ -0,0 +1,11 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml --key-id "${ARN1}" --description "The launch codes" --tag env=prod --tag team=ops launch_codes 0000
+biscuit list -f store.yaml --long | grep "The launch codes"
+biscuit list -f store.yaml --long | grep "env=prod,team=ops"
+biscuit list -f store.yaml --long | grep "arn:aws:"
+biscuit put -f store.yaml --tag env=staging launch_codes 1111
+biscuit list -f store.yaml --long | grep "env=staging,team=ops"
+biscuit list -f store.yaml --long | grep "The launch codes"
+grep "created:" store.yaml
+[[ "launch_codes" == "$(biscuit list -f store.yaml)" ]]
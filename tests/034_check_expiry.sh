@@ -0,0 +1,28 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml --key-id "${ARN1}" --expires 90d launch_codes 0000
+biscuit put -f store.yaml --rotate-every 90d password god
+biscuit check-expiry -f store.yaml
+! biscuit check-expiry -f store.yaml --within 100d
+biscuit check-expiry -f store.yaml --within 100d launch_codes | grep "expires soon"
+biscuit check-expiry -f store.yaml --within 100d password | grep "rotation due soon"
+biscuit put -f store.yaml --expires 2001-01-01 launch_codes 1111
+! biscuit check-expiry -f store.yaml
+# Re-encrypting keeps the expiry, but a new value does not inherit it.
+biscuit rotate -f store.yaml --all
+! biscuit check-expiry -f store.yaml launch_codes
+biscuit put -f store.yaml launch_codes 2222
+biscuit check-expiry -f store.yaml launch_codes
+biscuit put -f store.yaml --expires 2001-01-01 launch_codes 3333
+# The check reads only metadata, so it works without reaching KMS.
+AWS_ENDPOINT=http://127.0.0.1:1 biscuit check-expiry -f store.yaml launch_codes | grep expired
+AWS_ENDPOINT=http://127.0.0.1:1 biscuit check-expiry -f store.yaml password
+# Rotation is due from the last change of the value, which re-encrypting does not reset.
+biscuit put -f store.yaml --rotate-every 1d stale old
+sed -i 's/changed: .*/changed: 2001-01-01T00:00:00Z/' store.yaml
+! biscuit check-expiry -f store.yaml stale
+biscuit rotate -f store.yaml --all
+biscuit check-expiry -f store.yaml stale | grep "rotation overdue"
+biscuit put -f store.yaml --clear-expiry stale new
+biscuit check-expiry -f store.yaml stale
+! biscuit put -f store.yaml --clear-expiry --expires 1d stale new
@@ -0,0 +1,16 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml password god --key-id "${ARN1}","${ARN2}"
+biscuit put -f store.yaml username oreilly
+biscuit verify -f store.yaml
+[[ "4" == "$(biscuit verify -f store.yaml | grep -c ' ok$')" ]]
+
+# get falls back to the other region, but verify reports the damage.
+cp store.yaml corrupt1.yaml
+sed -i "s@${ARN1_REGION}@xxx@g" corrupt1.yaml
+[[ "god" == "$(biscuit get -f corrupt1.yaml password)" ]]
+! biscuit verify -f corrupt1.yaml
+biscuit verify -f corrupt1.yaml | grep "password .*xxx.*failed"
+biscuit verify -f corrupt1.yaml | grep "username .*${ARN2}.*ok"
+biscuit verify -f corrupt1.yaml password username 2>&1 | grep "2 of 4 values failed to verify."
+biscuit verify -f corrupt1.yaml | grep -E "${ARN2} +2 +0"
@@ -0,0 +1,17 @@
+#!/bin/bash -x
+set -e
+export BISCUIT_PASSPHRASE="correct horse battery staple"
+biscuit put -f store.yaml -p passphrase -k argon2id password god
+biscuit put -f store.yaml -p passphrase -k "scrypt:n=16384" username oreilly
+[[ "god" == "$(biscuit get -f store.yaml password)" ]]
+[[ "oreilly" == "$(biscuit get -f store.yaml username)" ]]
+# Settings are separated by semicolons, so they are not mistaken for separate keys.
+biscuit put -f store.yaml -p passphrase -k "argon2id:time=1;memory=65536;threads=1" pin 1234
+biscuit put -f store.yaml -k "passphrase:scrypt:n=16384;r=8;p=2" account 123456789012
+[[ "1234" == "$(biscuit get -f store.yaml pin)" ]]
+[[ "123456789012" == "$(biscuit get -f store.yaml account)" ]]
+grep -F "key_id: argon2id:time=1;memory=65536;threads=1" store.yaml
+grep -F "key_id: scrypt:n=16384;r=8;p=2" store.yaml
+grep "key_manager: passphrase" store.yaml
+! BISCUIT_PASSPHRASE="wrong" biscuit get -f store.yaml password
+! env -u BISCUIT_PASSPHRASE biscuit get -f store.yaml password </dev/null
@@ -0,0 +1,18 @@
+#!/bin/bash -x
+set -e
+# A throwaway identity used only by this test.
+export BISCUIT_AGE_IDENTITY=AGE-SECRET-KEY-1TKQN2T7RWX3260MRLHDZAWQ4FRUARZ2VETU6JFVU360L6346AG3SUN7AFL
+RECIPIENT=age1c6ssdya2hukpz59m736aeq5qs8zgq5ymjzgfrkjg84nlpnkky96shk4dx2
+biscuit put -f store.yaml -k "${ARN1},age:${RECIPIENT}" password god
+grep "key_manager: age" store.yaml
+biscuit verify -f store.yaml
+[[ "god" == "$(biscuit get -f store.yaml password)" ]]
+
+# The age value is a fallback when KMS is unavailable.
+cp store.yaml corrupt.yaml
+sed -i "s@${ARN1_REGION}@xxx@g" corrupt.yaml
+[[ "god" == "$(biscuit get -f corrupt.yaml password)" ]]
+! env -u BISCUIT_AGE_IDENTITY biscuit get -f corrupt.yaml password
+
+biscuit put -f age.yaml -p age -k "${RECIPIENT}" password god
+[[ "god" == "$(biscuit get -f age.yaml password)" ]]
@@ -0,0 +1,28 @@
+#!/bin/bash -x
+set -e
+# A throwaway identity used only by this test.
+export BISCUIT_AGE_IDENTITY=AGE-SECRET-KEY-1TKQN2T7RWX3260MRLHDZAWQ4FRUARZ2VETU6JFVU360L6346AG3SUN7AFL
+RECIPIENT=age1c6ssdya2hukpz59m736aeq5qs8zgq5ymjzgfrkjg84nlpnkky96shk4dx2
+biscuit put -f store.yaml -p shamir -k "2|kms:${ARN1}|kms:${ARN2}|age:${RECIPIENT}" password god
+grep "key_manager: shamir" store.yaml
+biscuit verify -f store.yaml
+[[ "god" == "$(biscuit get -f store.yaml password)" ]]
+
+# Any two custodians are enough.
+[[ "god" == "$(env -u BISCUIT_AGE_IDENTITY biscuit get -f store.yaml password)" ]]
+
+# One is not.
+biscuit put -f one.yaml -p shamir -k "2|kms:${ARN1}|age:${RECIPIENT}" password god
+[[ "god" == "$(biscuit get -f one.yaml password)" ]]
+! env -u BISCUIT_AGE_IDENTITY biscuit get -f one.yaml password
+
+# The number of custodians needed cannot exceed the number of custodians.
+! biscuit put -f invalid.yaml -p shamir -k "3|kms:${ARN1}|age:${RECIPIENT}" password god
+
+# A value encrypted under custodians given by alias still matches the template, so rotate leaves it alone.
+ALIAS="arn:aws:kms:${ARN1_REGION}:${AWS_ACCOUNT}:alias/biscuit-default"
+biscuit put -f alias.yaml -p shamir -k "2|kms:${ALIAS}|kms:${ARN2}|age:${RECIPIENT}" password god
+grep -F "key_id: 2|kms:${ALIAS}|" alias.yaml
+[[ "" == "$(biscuit rotate -f alias.yaml --dry-run)" ]]
+biscuit rotate -f alias.yaml
+[[ "god" == "$(biscuit get -f alias.yaml password)" ]]
@@ -0,0 +1,33 @@
+#!/bin/bash -x
+set -e
+# A plugin that stores the data key as its own ciphertext, which is only good enough for a test.
+mkdir -p plugins
+cat > plugins/biscuit-keymanager-insecure <<'PLUGIN'
+#!/bin/bash
+request=$(cat)
+case "${request}" in
+*'"method":"generate"'*)
+  echo '{"key_id":"resolved","plaintext":"eHh4eHh4eHh4eHh4eHh4eHh4eHh4eHh4eHh4eHh4eHg=","key_ciphertext":"eHh4eHh4eHh4eHh4eHh4eHh4eHh4eHh4eHh4eHh4eHg="}'
+  ;;
+*'"method":"decrypt"'*)
+  echo "{\"plaintext\":\"$(echo "${request}" | sed -n 's/.*"key_ciphertext":"\([^"]*\)".*/\1/p')\"}"
+  ;;
+*)
+  echo '{"error":"unknown method"}'
+  ;;
+esac
+PLUGIN
+chmod +x plugins/biscuit-keymanager-insecure
+export PATH="$(pwd)/plugins:${PATH}"
+
+biscuit put -f store.yaml -p plugin:insecure -k key password god
+grep "key_manager: plugin:insecure" store.yaml
+grep "key_id: resolved" store.yaml
+[[ "god" == "$(biscuit get -f store.yaml password)" ]]
+
+biscuit put -f mixed.yaml -k "${ARN1},plugin:insecure:key" password god
+grep "key_manager: plugin:insecure" mixed.yaml
+[[ "god" == "$(biscuit get -f mixed.yaml password)" ]]
+
+! biscuit put -f missing.yaml -p plugin:missing -k key password god
+! biscuit put -f invalid.yaml -p plugin:../insecure -k key password god
@@ -0,0 +1,15 @@
+#!/bin/bash -x
+set -e
+export XDG_CACHE_HOME=$(pwd)/cache
+biscuit put -f store.yaml -p kms-rsa -k "${RSA_ARN}" password god
+grep "key_manager: kms-rsa" store.yaml
+[[ "god" == "$(biscuit get -f store.yaml password)" ]]
+
+# The public key is cached, so later puts do not need KMS.
+ls cache/biscuit/kms-public-keys/*.json
+biscuit put -f store.yaml -p kms-rsa -k "${RSA_ARN}" username oreilly
+AWS_ENDPOINT=http://localhost:1 biscuit put -f store.yaml -p kms-rsa -k "${RSA_ARN}" username oreilly
+[[ "oreilly" == "$(biscuit get -f store.yaml username)" ]]
+
+# Symmetric keys cannot be used as RSA keys.
+! biscuit put -f invalid.yaml -p kms-rsa -k "${ARN1}" password god
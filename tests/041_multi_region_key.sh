@@ -0,0 +1,11 @@
+#!/bin/bash -x
+set -e
+biscuit put -f store.yaml -k "${MRK_ARN}" password god
+[[ "1" == "$(grep -c "key_id: ${MRK_ARN}" store.yaml)" ]]
+
+# The replica in the preferred region decrypts the value stored under the primary key.
+[[ "god" == "$(biscuit get -f store.yaml --aws-region-priority ${ARN2_REGION} password)" ]]
+[[ "god" == "$(biscuit get -f store.yaml --aws-region-priority ${ARN1_REGION} password)" ]]
+
+# Regions without a replica fall back to the region of the stored key.
+[[ "god" == "$(biscuit get -f store.yaml --aws-region-priority xx-nowhere-1 password)" ]]
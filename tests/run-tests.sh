@@ -24,6 +24,24 @@ export KEY2=$(aws --region=${REGION2} kms create-key | jq -r '.KeyMetadata.KeyId
 export ARN2=arn:aws:kms:${REGION2}:${AWS_ACCOUNT}:key/${KEY2}
 aws --region=${REGION2} kms create-alias --alias-name alias/biscuit-default --target-key-id ${ARN2}
 
+export RSA_KEY=$(aws --region=${REGION1} kms create-key --key-spec RSA_2048 --key-usage ENCRYPT_DECRYPT | \
+  jq -r '.KeyMetadata.KeyId')
+export RSA_ARN=arn:aws:kms:${REGION1}:${AWS_ACCOUNT}:key/${RSA_KEY}
+
+export MRK_KEY=$(aws --region=${REGION1} kms create-key --multi-region | jq -r '.KeyMetadata.KeyId')
+export MRK_ARN=arn:aws:kms:${REGION1}:${AWS_ACCOUNT}:key/${MRK_KEY}
+aws --region=${REGION1} kms replicate-key --key-id ${MRK_ARN} --replica-region ${REGION2} >/dev/null
+
+export BUCKET=biscuit-tests
+aws --region=${REGION1} s3api create-bucket --bucket ${BUCKET} \
+  --create-bucket-configuration LocationConstraint=${REGION1} 2>/dev/null || echo "Bucket exists"
+
+export TABLE=biscuit-tests
+aws --region=${REGION1} dynamodb create-table --table-name ${TABLE} \
+  --attribute-definitions AttributeName=name,AttributeType=S AttributeName=version,AttributeType=N \
+  --key-schema AttributeName=name,KeyType=HASH AttributeName=version,KeyType=RANGE \
+  --billing-mode PAY_PER_REQUEST >/dev/null 2>&1 || echo "Table exists"
+
 function invoke_one() {
   docker run \
     --network=localstack \
@@ -42,6 +60,10 @@ function invoke_one() {
     -e ARN2_REGION=${REGION2} \
     -e KEY2=${KEY2} \
     -e ARN2=${ARN2} \
+    -e RSA_ARN=${RSA_ARN} \
+    -e MRK_ARN=${MRK_ARN} \
+    -e BUCKET=${BUCKET} \
+    -e TABLE=${TABLE} \
     --entrypoint=/bin/bash \
     ghcr.io/dcoker/biscuit:latest \
     -c "$@"